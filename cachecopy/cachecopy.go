@@ -2,10 +2,14 @@ package cachecopy
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+
+	"github.com/WillAbides/checksum/internal/ctxio"
 )
 
 type Cache interface {
@@ -13,10 +17,31 @@ type Cache interface {
 	Reader() (io.ReadCloser, error)
 }
 
+//discarder is implemented by caches that can throw away data that has already been written to them
+type discarder interface {
+	discard() error
+}
+
 type Validator func(io.Reader) (bool, string)
 
+//StreamingValidator validates data while it is being written to the cache, like a hash.Hash that compares its sum.
+//Verify is called once after all the data has been written and returns the same values as a Validator.
+//Because the data is validated as the cache is filled, it only needs to be read back from the cache once.
+type StreamingValidator interface {
+	io.Writer
+	Verify() (bool, string)
+}
+
+var (
+	//ErrNilValidator is returned when Copy is called without a validator
+	ErrNilValidator = errors.New("validator cannot be nil")
+	//ErrValidationFailed matches any *ValidatorError with errors.Is
+	ErrValidationFailed = errors.New("validation failed")
+)
+
 type ValidatorError struct {
 	msg string
+	err error
 }
 
 func (e *ValidatorError) Error() string {
@@ -26,13 +51,38 @@ func (e *ValidatorError) Error() string {
 	return fmt.Sprintf("validator returned false with the message: %q", e.msg)
 }
 
+//Is returns true when target is ErrValidationFailed
+func (e *ValidatorError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
+
+//Unwrap returns the error that caused validation to fail. It is only set by the validators in this package, for
+//example a *sumchecker.MismatchError from ChecksumValidator.
+func (e *ValidatorError) Unwrap() error {
+	return e.err
+}
+
+//Copier copies data that passes validation. At least one of Validator and NewStreamingValidator must be set.
+//When both are set, the data must pass both.
 type Copier struct {
 	Cache     Cache
 	Validator Validator
+	//NewStreamingValidator is called on every copy because a StreamingValidator can only validate the data it is
+	//written once
+	NewStreamingValidator func() StreamingValidator
 }
 
 func (c *Copier) Copy(dst io.Writer, src io.Reader) (int64, error) {
-	return Copy(dst, src, c.Validator, c.Cache)
+	return c.CopyContext(context.Background(), dst, src)
+}
+
+//CopyContext is like Copy but stops and returns ctx.Err() when ctx is done
+func (c *Copier) CopyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	var streaming StreamingValidator
+	if c.NewStreamingValidator != nil {
+		streaming = c.NewStreamingValidator()
+	}
+	return copyContext(ctx, dst, src, c.Validator, streaming, c.Cache)
 }
 
 func NewBufferCache(buf *bytes.Buffer) Cache {
@@ -53,53 +103,160 @@ func (c *bufferCache) Reader() (io.ReadCloser, error) {
 }
 
 func (c *bufferCache) Close() error {
+	return c.discard()
+}
+
+func (c *bufferCache) discard() error {
+	c.Buffer.Reset()
 	return nil
 }
 
+//NewFileCache returns a Cache that writes to file. The caller is responsible for removing file.
+//Readers read from file's descriptor, so it works with files that have been unlinked.
 func NewFileCache(file *os.File) Cache {
 	return &fileCache{
-		File: *file,
+		File: file,
 	}
 }
 
 type fileCache struct {
-	os.File
+	*os.File
 }
 
 func (c *fileCache) Reader() (io.ReadCloser, error) {
-	return os.Open(c.File.Name())
+	info, err := c.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(io.NewSectionReader(c.File, 0, info.Size())), nil
 }
 
+func (c *fileCache) discard() error {
+	err := c.File.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = c.File.Seek(0, io.SeekStart)
+	return err
+}
+
+//NewTempFileCache returns a Cache that writes to a new temp file created with ioutil.TempFile(dir, pattern).
+//The temp file is removed when the cache is closed.
+func NewTempFileCache(dir, pattern string) (Cache, error) {
+	file, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &tempFileCache{
+		fileCache: fileCache{
+			File: file,
+		},
+	}, nil
+}
+
+type tempFileCache struct {
+	fileCache
+	closed bool
+}
+
+//Close closes and removes the temp file. It is safe to call more than once.
+func (c *tempFileCache) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	err := c.File.Close()
+	removeErr := os.Remove(c.File.Name())
+	if err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+//Copy reads src into cache, checks it with validator and copies it to dst only if validator returns true.
+//When cache is nil, it uses NewSpillCache with DefaultMaxMemory.
 func Copy(dst io.Writer, src io.Reader, validator func(io.Reader) (bool, string), cache Cache) (written int64, err error) {
+	return CopyContext(context.Background(), dst, src, validator, cache)
+}
+
+//CopyContext is like Copy but stops and returns ctx.Err() when ctx is done.
+//When ctx is done before the copy finishes, data already written to cache is discarded.
+func CopyContext(ctx context.Context, dst io.Writer, src io.Reader, validator func(io.Reader) (bool, string), cache Cache) (written int64, err error) {
 	if validator == nil {
-		return written, fmt.Errorf("validator cannot be nil")
+		return written, ErrNilValidator
+	}
+	return copyContext(ctx, dst, src, validator, nil, cache)
+}
+
+//CopyStreaming is like Copy but validates src with validator while it is written to cache, so cache is only read once.
+func CopyStreaming(dst io.Writer, src io.Reader, validator StreamingValidator, cache Cache) (written int64, err error) {
+	return CopyStreamingContext(context.Background(), dst, src, validator, cache)
+}
+
+//CopyStreamingContext is like CopyStreaming but stops and returns ctx.Err() when ctx is done.
+//When ctx is done before the copy finishes, data already written to cache is discarded.
+func CopyStreamingContext(ctx context.Context, dst io.Writer, src io.Reader, validator StreamingValidator, cache Cache) (written int64, err error) {
+	if validator == nil {
+		return written, ErrNilValidator
+	}
+	return copyContext(ctx, dst, src, nil, validator, cache)
+}
+
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader, validator Validator, streaming StreamingValidator, cache Cache) (written int64, err error) {
+	if validator == nil && streaming == nil {
+		return written, ErrNilValidator
 	}
 	if cache == nil {
-		cache = NewBufferCache(nil)
+		cache = NewSpillCache(DefaultMaxMemory, "")
 	}
 	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+			if d, ok := cache.(discarder); ok {
+				_ = d.discard()
+			}
+		}
 		_ = cache.Close()
 	}()
-	_, err = io.Copy(cache, src)
-	if err != nil {
-		return written, fmt.Errorf("error copying to cache")
+	cacheSrc := ctxio.NewReader(ctx, src)
+	if streaming != nil {
+		cacheSrc = io.TeeReader(cacheSrc, streaming)
 	}
-	vReader, err := cache.Reader()
+	_, err = io.Copy(cache, cacheSrc)
 	if err != nil {
-		return written, fmt.Errorf("error getting cache reader")
+		if streaming != nil {
+			//let streaming clean up what it has started. the result doesn't matter.
+			_, _ = streaming.Verify()
+		}
+		return written, fmt.Errorf("error copying to cache: %w", err)
+	}
+	if streaming != nil {
+		err = verifyErr(streaming)
+		if err != nil {
+			return written, validatorError(err)
+		}
 	}
-	ok, validatorMsg := validator(vReader)
-	_ = vReader.Close()
-	if !ok {
-		return written, &ValidatorError{msg: validatorMsg}
+	if validator != nil {
+		vReader, err := cache.Reader()
+		if err != nil {
+			return written, fmt.Errorf("error getting cache reader: %w", err)
+		}
+		ok, validatorMsg := validator(ctxio.NewReader(ctx, vReader))
+		_ = vReader.Close()
+		if ctx.Err() != nil {
+			return written, ctx.Err()
+		}
+		if !ok {
+			return written, &ValidatorError{msg: validatorMsg}
+		}
 	}
 	rdr, err := cache.Reader()
 	if err != nil {
-		return written, fmt.Errorf("error getting cache reader")
+		return written, fmt.Errorf("error getting cache reader: %w", err)
 	}
 	defer func() {
 		_ = rdr.Close()
 	}()
-	written, err = io.Copy(dst, rdr)
+	written, err = io.Copy(dst, ctxio.NewReader(ctx, rdr))
 	return written, err
 }
@@ -2,10 +2,16 @@ package cachecopy
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
 	"io"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -76,8 +82,15 @@ func TestCopy(t *testing.T) {
 			var dst bytes.Buffer
 			_, err := Copy(&dst, loremBuf(t), failingValidator, cache)
 			assert.Equal(t, failingValidatorErr, err)
+			assert.True(t, errors.Is(err, ErrValidationFailed))
 			assert.Empty(t, dst.String())
 		})
+
+		t.Run("nil validator", func(t *testing.T) {
+			var dst bytes.Buffer
+			_, err := Copy(&dst, loremBuf(t), nil, nil)
+			assert.Equal(t, ErrNilValidator, err)
+		})
 	})
 
 	t.Run("file", func(t *testing.T) {
@@ -103,3 +116,263 @@ func TestCopy(t *testing.T) {
 		})
 	})
 }
+
+//cancelingReader cancels its context once limit bytes have been read from r
+type cancelingReader struct {
+	cancel context.CancelFunc
+	r      *bytes.Buffer
+	limit  int
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	if r.limit <= 0 {
+		r.cancel()
+	}
+	if len(p) > r.limit && r.limit > 0 {
+		p = p[:r.limit]
+	}
+	n, err := r.r.Read(p)
+	r.limit -= n
+	return n, err
+}
+
+func newCancelingReader(t *testing.T) (context.Context, io.Reader) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	buf := loremBuf(t)
+	return ctx, &cancelingReader{
+		cancel: cancel,
+		r:      buf,
+		limit:  buf.Len() / 2,
+	}
+}
+
+func TestCopyContext(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		var dst bytes.Buffer
+		copier := &Copier{
+			Validator: loremValidator(t),
+		}
+		_, err := copier.CopyContext(context.Background(), &dst, loremBuf(t))
+		assert.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+	})
+
+	t.Run("canceled with buffer", func(t *testing.T) {
+		var buf bytes.Buffer
+		cache := NewBufferCache(&buf)
+		var dst bytes.Buffer
+		ctx, src := newCancelingReader(t)
+		written, err := CopyContext(ctx, &dst, src, loremValidator(t), cache)
+		assert.Equal(t, context.Canceled, err)
+		assert.Zero(t, written)
+		assert.Empty(t, dst.String())
+		assert.Zero(t, cache.(*bufferCache).Len())
+	})
+
+	t.Run("canceled with file", func(t *testing.T) {
+		cacheFile, cacheTeardown := tmpFile(t)
+		defer cacheTeardown()
+		cache := NewFileCache(cacheFile)
+		var dst bytes.Buffer
+		ctx, src := newCancelingReader(t)
+		written, err := CopyContext(ctx, &dst, src, loremValidator(t), cache)
+		assert.Equal(t, context.Canceled, err)
+		assert.Zero(t, written)
+		assert.Empty(t, dst.String())
+		info, err := os.Stat(cacheFile.Name())
+		require.NoError(t, err)
+		assert.Zero(t, info.Size())
+	})
+
+	t.Run("canceled during validation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var dst bytes.Buffer
+		validator := func(rdr io.Reader) (bool, string) {
+			cancel()
+			_, err := ioutil.ReadAll(rdr)
+			return err == nil, ""
+		}
+		_, err := CopyContext(ctx, &dst, loremBuf(t), validator, nil)
+		assert.Equal(t, context.Canceled, err)
+		assert.Empty(t, dst.String())
+	})
+}
+
+func TestTempFileCache(t *testing.T) {
+	t.Run("concurrent readers", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		defer teardown()
+		cache, err := NewTempFileCache(dir, "")
+		require.NoError(t, err)
+		_, err = io.Copy(cache, loremBuf(t))
+		require.NoError(t, err)
+		var wg sync.WaitGroup
+		results := make([]string, 8)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				rdr, err := cache.Reader()
+				if err != nil {
+					return
+				}
+				defer func() {
+					_ = rdr.Close()
+				}()
+				got, err := ioutil.ReadAll(iotest.OneByteReader(rdr))
+				if err != nil {
+					return
+				}
+				results[i] = string(got)
+			}(i)
+		}
+		wg.Wait()
+		for _, got := range results {
+			assert.Equal(t, loremBuf(t).String(), got)
+		}
+		assert.Len(t, dirEntries(t, dir), 1)
+		assert.NoError(t, cache.Close())
+		assert.Empty(t, dirEntries(t, dir))
+		assert.NoError(t, cache.Close())
+	})
+
+	t.Run("removed after validator failure", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		defer teardown()
+		cache, err := NewTempFileCache(dir, "cache-*")
+		require.NoError(t, err)
+		var dst bytes.Buffer
+		_, err = Copy(&dst, loremBuf(t), failingValidator, cache)
+		assert.Equal(t, failingValidatorErr, err)
+		assert.Empty(t, dst.String())
+		assert.Empty(t, dirEntries(t, dir))
+	})
+
+	t.Run("invalid dir", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		teardown()
+		cache, err := NewTempFileCache(dir, "")
+		assert.Error(t, err)
+		assert.Nil(t, cache)
+	})
+}
+
+func TestFileCache_unlinked(t *testing.T) {
+	cacheFile, cacheTeardown := tmpFile(t)
+	cacheTeardown()
+	var dst bytes.Buffer
+	_, err := Copy(&dst, loremBuf(t), loremValidator(t), NewFileCache(cacheFile))
+	assert.NoError(t, err)
+	assert.Equal(t, loremBuf(t).String(), dst.String())
+}
+
+//sha256Validator is a StreamingValidator that checks the sha256 checksum of everything written to it
+type sha256Validator struct {
+	hash.Hash
+	want []byte
+}
+
+func newSHA256Validator(want []byte) *sha256Validator {
+	return &sha256Validator{
+		Hash: sha256.New(),
+		want: want,
+	}
+}
+
+func (v *sha256Validator) Verify() (bool, string) {
+	if !bytes.Equal(v.want, v.Sum(nil)) {
+		return false, "checksum mismatch"
+	}
+	return true, ""
+}
+
+//countingCache counts calls to Reader
+type countingCache struct {
+	Cache
+	readers int
+}
+
+func (c *countingCache) Reader() (io.ReadCloser, error) {
+	c.readers++
+	return c.Cache.Reader()
+}
+
+func TestCopyStreaming(t *testing.T) {
+	loremSum := sha256.Sum256(loremBuf(t).Bytes())
+
+	t.Run("valid", func(t *testing.T) {
+		cache := &countingCache{Cache: NewBufferCache(nil)}
+		var dst bytes.Buffer
+		written, err := CopyStreaming(&dst, loremBuf(t), newSHA256Validator(loremSum[:]), cache)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(loremBuf(t).Len()), written)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+		assert.Equal(t, 1, cache.readers)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cache := &countingCache{Cache: NewBufferCache(nil)}
+		var dst bytes.Buffer
+		_, err := CopyStreaming(&dst, loremBuf(t), newSHA256Validator([]byte("bogus")), cache)
+		assert.Equal(t, &ValidatorError{msg: "checksum mismatch"}, err)
+		assert.Empty(t, dst.String())
+		assert.Zero(t, cache.readers)
+	})
+
+	t.Run("nil validator", func(t *testing.T) {
+		var dst bytes.Buffer
+		_, err := CopyStreaming(&dst, loremBuf(t), nil, nil)
+		assert.Equal(t, ErrNilValidator, err)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		var buf bytes.Buffer
+		cache := NewBufferCache(&buf)
+		var dst bytes.Buffer
+		ctx, src := newCancelingReader(t)
+		_, err := CopyStreamingContext(ctx, &dst, src, newSHA256Validator(loremSum[:]), cache)
+		assert.Equal(t, context.Canceled, err)
+		assert.Empty(t, dst.String())
+		assert.Zero(t, cache.(*bufferCache).Len())
+	})
+
+	newLoremValidator := func() StreamingValidator {
+		return newSHA256Validator(loremSum[:])
+	}
+
+	t.Run("copier with both validators", func(t *testing.T) {
+		var dst bytes.Buffer
+		copier := &Copier{
+			Validator:             loremValidator(t),
+			NewStreamingValidator: newLoremValidator,
+		}
+		_, err := copier.Copy(&dst, loremBuf(t))
+		assert.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+
+		dst.Reset()
+		copier = &Copier{
+			Validator:             failingValidator,
+			NewStreamingValidator: newLoremValidator,
+		}
+		_, err = copier.Copy(&dst, loremBuf(t))
+		assert.Equal(t, failingValidatorErr, err)
+		assert.Empty(t, dst.String())
+	})
+
+	t.Run("reused copier", func(t *testing.T) {
+		copier := &Copier{
+			NewStreamingValidator: newLoremValidator,
+		}
+		var dst bytes.Buffer
+		_, err := copier.Copy(&dst, loremBuf(t))
+		assert.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+
+		dst.Reset()
+		_, err = copier.Copy(&dst, bytes.NewBufferString("EVIL"))
+		assert.Equal(t, &ValidatorError{msg: "checksum mismatch"}, err)
+		assert.Empty(t, dst.String())
+	})
+}
@@ -0,0 +1,73 @@
+package cachecopy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+//DefaultMaxMemory is the memory limit of the spill cache Copy uses when it isn't given a cache
+const DefaultMaxMemory int64 = 32 << 20
+
+//NewSpillCache returns a Cache that holds up to memLimit bytes in memory. When more than memLimit bytes are written,
+//everything is moved to a temp file in dir and the rest is written there. An empty dir uses the default directory
+//for temporary files. The temp file is removed when the cache is closed.
+func NewSpillCache(memLimit int64, dir string) Cache {
+	return &spillCache{
+		memLimit: memLimit,
+		dir:      dir,
+	}
+}
+
+type spillCache struct {
+	memLimit int64
+	dir      string
+	buf      bytes.Buffer
+	file     Cache
+}
+
+func (c *spillCache) Write(p []byte) (int, error) {
+	if c.file == nil && int64(c.buf.Len()+len(p)) > c.memLimit {
+		err := c.spill()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if c.file == nil {
+		return c.buf.Write(p)
+	}
+	return c.file.Write(p)
+}
+
+//spill moves the buffered data to a new temp file cache
+func (c *spillCache) spill() error {
+	file, err := NewTempFileCache(c.dir, "cachecopy-*")
+	if err != nil {
+		return err
+	}
+	c.file = file
+	_, err = c.buf.WriteTo(file)
+	c.buf = bytes.Buffer{}
+	return err
+}
+
+func (c *spillCache) Reader() (io.ReadCloser, error) {
+	if c.file == nil {
+		return ioutil.NopCloser(bytes.NewReader(c.buf.Bytes())), nil
+	}
+	return c.file.Reader()
+}
+
+func (c *spillCache) Close() error {
+	return c.discard()
+}
+
+func (c *spillCache) discard() error {
+	c.buf = bytes.Buffer{}
+	if c.file == nil {
+		return nil
+	}
+	file := c.file
+	c.file = nil
+	return file.Close()
+}
@@ -0,0 +1,109 @@
+package cachecopy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tmpDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	return dir, func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names
+}
+
+func readCache(t *testing.T, cache Cache) string {
+	t.Helper()
+	rdr, err := cache.Reader()
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(rdr)
+	require.NoError(t, err)
+	require.NoError(t, rdr.Close())
+	return string(got)
+}
+
+func TestSpillCache(t *testing.T) {
+	t.Run("in memory", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		defer teardown()
+		cache := NewSpillCache(int64(loremBuf(t).Len()), dir)
+		_, err := io.Copy(cache, loremBuf(t))
+		require.NoError(t, err)
+		assert.Nil(t, cache.(*spillCache).file)
+		assert.Empty(t, dirEntries(t, dir))
+		assert.Equal(t, loremBuf(t).String(), readCache(t, cache))
+		assert.NoError(t, cache.Close())
+	})
+
+	t.Run("spilled", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		defer teardown()
+		cache := NewSpillCache(100, dir)
+		_, err := io.Copy(cache, loremBuf(t))
+		require.NoError(t, err)
+		assert.Len(t, dirEntries(t, dir), 1)
+		assert.Equal(t, loremBuf(t).String(), readCache(t, cache))
+		assert.Equal(t, loremBuf(t).String(), readCache(t, cache))
+		assert.NoError(t, cache.Close())
+		assert.Empty(t, dirEntries(t, dir))
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		defer teardown()
+		var dst bytes.Buffer
+		_, err := Copy(&dst, loremBuf(t), loremValidator(t), NewSpillCache(100, dir))
+		assert.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+		assert.Empty(t, dirEntries(t, dir))
+
+		dst.Reset()
+		_, err = Copy(&dst, loremBuf(t), failingValidator, NewSpillCache(100, dir))
+		assert.Equal(t, failingValidatorErr, err)
+		assert.Empty(t, dst.String())
+		assert.Empty(t, dirEntries(t, dir))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		defer teardown()
+		var dst bytes.Buffer
+		ctx, src := newCancelingReader(t)
+		_, err := CopyContext(ctx, &dst, src, loremValidator(t), NewSpillCache(100, dir))
+		assert.Equal(t, context.Canceled, err)
+		assert.Empty(t, dst.String())
+		assert.Empty(t, dirEntries(t, dir))
+	})
+
+	t.Run("bad dir", func(t *testing.T) {
+		dir, teardown := tmpDir(t)
+		defer teardown()
+		var dst bytes.Buffer
+		_, err := Copy(&dst, loremBuf(t), loremValidator(t), NewSpillCache(100, filepath.Join(dir, "missing")))
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+		assert.Contains(t, err.Error(), "error copying to cache: ")
+		assert.Empty(t, dst.String())
+	})
+}
@@ -0,0 +1,261 @@
+package cachecopy
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/WillAbides/checksum/knownsums"
+	"github.com/WillAbides/checksum/sumchecker"
+)
+
+//ErrValidatorUsed is returned by the StreamingValidators in this package when they are written to or verified after
+//they have already been verified. Each of them can only validate data once.
+var ErrValidatorUsed = errors.New("streaming validator has already been verified")
+
+//errVerifier is implemented by the StreamingValidators in this package. verify returns the reason validation failed
+//as an error so that ValidatorError can wrap it.
+type errVerifier interface {
+	verify() error
+}
+
+//verifyErr returns nil if v passes validation or the reason it failed
+func verifyErr(v StreamingValidator) error {
+	if ev, ok := v.(errVerifier); ok {
+		return ev.verify()
+	}
+	ok, msg := v.Verify()
+	if ok {
+		return nil
+	}
+	return &ValidatorError{msg: msg}
+}
+
+//validatorError wraps err in a *ValidatorError unless it already is one
+func validatorError(err error) error {
+	var vErr *ValidatorError
+	if errors.As(err, &vErr) {
+		return err
+	}
+	return &ValidatorError{
+		msg: err.Error(),
+		err: err,
+	}
+}
+
+//validatorMessage returns the message of a *ValidatorError from a validator outside this package or err.Error()
+func validatorMessage(err error) string {
+	var vErr *ValidatorError
+	if errors.As(err, &vErr) && vErr.msg != "" {
+		return vErr.msg
+	}
+	return err.Error()
+}
+
+func verifyResult(err error) (bool, string) {
+	if err == nil {
+		return true, ""
+	}
+	return false, err.Error()
+}
+
+//pipeValidator runs a func that validates an io.Reader in a goroutine and feeds it everything written to the
+//pipeValidator. The goroutine starts on the first Write or verify, so a pipeValidator that is never used doesn't leave
+//one behind.
+type pipeValidator struct {
+	fn       func(io.Reader) error
+	pw       *io.PipeWriter
+	done     chan struct{}
+	err      error
+	verified bool
+}
+
+func newPipeValidator(fn func(io.Reader) error) *pipeValidator {
+	return &pipeValidator{
+		fn: fn,
+	}
+}
+
+func (v *pipeValidator) start() {
+	if v.pw != nil {
+		return
+	}
+	pr, pw := io.Pipe()
+	v.pw = pw
+	v.done = make(chan struct{})
+	go func() {
+		defer close(v.done)
+		v.err = v.fn(pr)
+		//unblock writes when fn returns before reading everything
+		_ = pr.Close()
+	}()
+}
+
+//Write doesn't return an error when fn has already failed so that writing to other validators isn't interrupted.
+//It only fails after verify has been called.
+func (v *pipeValidator) Write(p []byte) (int, error) {
+	if v.verified {
+		return 0, ErrValidatorUsed
+	}
+	v.start()
+	_, _ = v.pw.Write(p)
+	return len(p), nil
+}
+
+func (v *pipeValidator) verify() error {
+	if v.verified {
+		return ErrValidatorUsed
+	}
+	v.verified = true
+	v.start()
+	_ = v.pw.Close()
+	<-v.done
+	return v.err
+}
+
+func (v *pipeValidator) Verify() (bool, string) {
+	return verifyResult(v.verify())
+}
+
+//ChecksumValidator returns a StreamingValidator that passes when the data's checksum matches wantSum.
+//When it fails, the ValidatorError wraps a *sumchecker.MismatchError.
+func ChecksumValidator(hash crypto.Hash, wantSum []byte) StreamingValidator {
+	return CheckerValidator(sumchecker.New(nil), hash, wantSum)
+}
+
+//CheckerValidator is like ChecksumValidator but calculates the checksum with checker
+func CheckerValidator(checker *sumchecker.Checker, hash crypto.Hash, wantSum []byte) StreamingValidator {
+	return newPipeValidator(func(r io.Reader) error {
+		return checker.VerifyReader(hash, wantSum, r)
+	})
+}
+
+//KnownSumsValidator returns a StreamingValidator that passes when the data matches the known sums in ks with the given
+//name and hash. Like KnownSums.Validate, a nil hash validates against every known sum with the given name.
+//When it fails, the ValidatorError wraps the error from knownsums.ValidationResult's Err.
+//The known sums are copied from ks with KnownSums.Select, so ks isn't locked while the data is validated and changes
+//made to ks after KnownSumsValidator returns don't affect the result.
+func KnownSumsValidator(ks *knownsums.KnownSums, name string, hash *crypto.Hash) StreamingValidator {
+	ks = ks.Select(name, hash)
+	return newPipeValidator(func(r io.Reader) error {
+		result, err := ks.ValidateDetailed(name, hash, r)
+		if err != nil {
+			return err
+		}
+		return result.Err()
+	})
+}
+
+//SizeValidator returns a StreamingValidator that passes when the data is at least min and at most max bytes.
+//A negative max means there is no maximum.
+func SizeValidator(min, max int64) StreamingValidator {
+	return &sizeValidator{
+		min: min,
+		max: max,
+	}
+}
+
+type sizeValidator struct {
+	min, max int64
+	size     int64
+	verified bool
+}
+
+func (v *sizeValidator) Write(p []byte) (int, error) {
+	if v.verified {
+		return 0, ErrValidatorUsed
+	}
+	v.size += int64(len(p))
+	return len(p), nil
+}
+
+func (v *sizeValidator) verify() error {
+	if v.verified {
+		return ErrValidatorUsed
+	}
+	v.verified = true
+	if v.size < v.min {
+		return fmt.Errorf("size %d is less than the minimum of %d", v.size, v.min)
+	}
+	if v.max >= 0 && v.size > v.max {
+		return fmt.Errorf("size %d is more than the maximum of %d", v.size, v.max)
+	}
+	return nil
+}
+
+func (v *sizeValidator) Verify() (bool, string) {
+	return verifyResult(v.verify())
+}
+
+//AllOf returns a StreamingValidator that passes when all of validators pass
+func AllOf(validators ...StreamingValidator) StreamingValidator {
+	return &multiValidator{
+		validators: validators,
+	}
+}
+
+//AnyOf returns a StreamingValidator that passes when at least one of validators passes
+func AnyOf(validators ...StreamingValidator) StreamingValidator {
+	return &multiValidator{
+		validators: validators,
+		any:        true,
+	}
+}
+
+type multiValidator struct {
+	validators []StreamingValidator
+	any        bool
+	verified   bool
+}
+
+func (v *multiValidator) Write(p []byte) (int, error) {
+	if v.verified {
+		return 0, ErrValidatorUsed
+	}
+	for _, validator := range v.validators {
+		n, err := validator.Write(p)
+		if err != nil {
+			return n, err
+		}
+		if n != len(p) {
+			return n, io.ErrShortWrite
+		}
+	}
+	return len(p), nil
+}
+
+//verify calls verify on every validator so that none of them are left waiting for more data
+func (v *multiValidator) verify() error {
+	if v.verified {
+		return ErrValidatorUsed
+	}
+	v.verified = true
+	errs := make([]error, 0, len(v.validators))
+	for _, validator := range v.validators {
+		err := verifyErr(validator)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch {
+	case len(errs) == 0:
+		return nil
+	case !v.any:
+		return errs[0]
+	case len(errs) < len(v.validators):
+		return nil
+	case len(errs) == 1:
+		return errs[0]
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = validatorMessage(err)
+	}
+	return fmt.Errorf("none of the validators passed: %s", strings.Join(msgs, "; "))
+}
+
+func (v *multiValidator) Verify() (bool, string) {
+	return verifyResult(v.verify())
+}
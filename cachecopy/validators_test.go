@@ -0,0 +1,248 @@
+package cachecopy
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/WillAbides/checksum/knownsums"
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumValidator(t *testing.T) {
+	loremSum := sha256.Sum256(loremBuf(t).Bytes())
+
+	t.Run("valid", func(t *testing.T) {
+		var dst bytes.Buffer
+		_, err := CopyStreaming(&dst, loremBuf(t), ChecksumValidator(crypto.SHA256, loremSum[:]), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var dst bytes.Buffer
+		_, err := CopyStreaming(&dst, loremBuf(t), ChecksumValidator(crypto.SHA256, []byte("bogus")), nil)
+		assert.True(t, errors.Is(err, ErrValidationFailed))
+		assert.True(t, errors.Is(err, sumchecker.ErrMismatch))
+		var mismatchErr *sumchecker.MismatchError
+		require.True(t, errors.As(err, &mismatchErr))
+		assert.Equal(t, loremSum[:], mismatchErr.Actual)
+		assert.Contains(t, err.Error(), "checksum mismatch using sha256")
+		assert.Empty(t, dst.String())
+	})
+
+	t.Run("unregistered hash", func(t *testing.T) {
+		var dst bytes.Buffer
+		_, err := CopyStreaming(&dst, loremBuf(t), ChecksumValidator(crypto.Hash(0), loremSum[:]), nil)
+		assert.True(t, errors.Is(err, ErrValidationFailed))
+		assert.True(t, errors.Is(err, sumchecker.ErrUnregisteredHash))
+		assert.Empty(t, dst.String())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		var dst bytes.Buffer
+		ctx, src := newCancelingReader(t)
+		_, err := CopyStreamingContext(ctx, &dst, src, ChecksumValidator(crypto.SHA256, loremSum[:]), nil)
+		assert.Equal(t, context.Canceled, err)
+		assert.Empty(t, dst.String())
+	})
+}
+
+func TestKnownSumsValidator(t *testing.T) {
+	ks := &knownsums.KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	require.NoError(t, ks.Add("lorem", crypto.SHA256, loremBuf(t).Bytes()))
+	require.NoError(t, ks.Add("lorem", crypto.SHA512, loremBuf(t).Bytes()))
+	require.NoError(t, ks.Add("other", crypto.SHA256, []byte("other")))
+	sha512 := crypto.SHA512
+
+	for _, td := range []struct {
+		name    string
+		sumName string
+		hash    *crypto.Hash
+		wantErr error
+	}{
+		{name: "all hashes", sumName: "lorem"},
+		{name: "one hash", sumName: "lorem", hash: &sha512},
+		{name: "mismatch", sumName: "other", wantErr: knownsums.ErrMismatch},
+		{name: "not found", sumName: "missing", wantErr: knownsums.ErrNotFound},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			var dst bytes.Buffer
+			_, err := CopyStreaming(&dst, loremBuf(t), KnownSumsValidator(ks, td.sumName, td.hash), nil)
+			if td.wantErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, loremBuf(t).String(), dst.String())
+				return
+			}
+			assert.True(t, errors.Is(err, ErrValidationFailed))
+			assert.True(t, errors.Is(err, td.wantErr))
+			assert.Empty(t, dst.String())
+		})
+	}
+}
+
+//hookReader calls hook before its second Read, after the first one has been written to the validator
+type hookReader struct {
+	io.Reader
+	hook  func()
+	reads int
+}
+
+func (r *hookReader) Read(p []byte) (int, error) {
+	r.reads++
+	if r.reads == 2 {
+		r.hook()
+	}
+	return r.Reader.Read(p)
+}
+
+func TestKnownSumsValidator_setDuringCopy(t *testing.T) {
+	ks := &knownsums.KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	require.NoError(t, ks.Add("lorem", crypto.SHA256, loremBuf(t).Bytes()))
+	var changed StreamingValidator
+	src := &hookReader{
+		Reader: iotest.HalfReader(loremBuf(t)),
+		hook: func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, err := ks.Set("lorem", crypto.SHA256, []byte("changed"))
+				assert.NoError(t, err)
+				changed = KnownSumsValidator(ks, "lorem", nil)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Set was blocked by the copy")
+			}
+		},
+	}
+	var dst bytes.Buffer
+	_, err := CopyStreaming(&dst, src, KnownSumsValidator(ks, "lorem", nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, loremBuf(t).String(), dst.String())
+
+	dst.Reset()
+	_, err = CopyStreaming(&dst, loremBuf(t), changed, nil)
+	assert.True(t, errors.Is(err, knownsums.ErrMismatch))
+	assert.Empty(t, dst.String())
+}
+
+func TestSizeValidator(t *testing.T) {
+	size := int64(loremBuf(t).Len())
+	for _, td := range []struct {
+		name     string
+		min, max int64
+		wantMsg  string
+	}{
+		{name: "exact", min: size, max: size},
+		{name: "no max", min: 1, max: -1},
+		{name: "too small", min: size + 1, max: -1, wantMsg: "size 86700 is less than the minimum of 86701"},
+		{name: "too big", max: size - 1, wantMsg: "size 86700 is more than the maximum of 86699"},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			v := SizeValidator(td.min, td.max)
+			_, err := loremBuf(t).WriteTo(v)
+			require.NoError(t, err)
+			ok, msg := v.Verify()
+			assert.Equal(t, td.wantMsg == "", ok)
+			assert.Equal(t, td.wantMsg, msg)
+		})
+	}
+}
+
+func TestAllOf(t *testing.T) {
+	loremSum := sha256.Sum256(loremBuf(t).Bytes())
+	size := int64(loremBuf(t).Len())
+
+	t.Run("all pass", func(t *testing.T) {
+		var dst bytes.Buffer
+		validator := AllOf(ChecksumValidator(crypto.SHA256, loremSum[:]), SizeValidator(size, size))
+		_, err := CopyStreaming(&dst, loremBuf(t), validator, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+	})
+
+	t.Run("one fails", func(t *testing.T) {
+		var dst bytes.Buffer
+		validator := AllOf(ChecksumValidator(crypto.SHA256, loremSum[:]), SizeValidator(0, 10))
+		_, err := CopyStreaming(&dst, loremBuf(t), validator, nil)
+		assert.Equal(t, `validator returned false with the message: "size 86700 is more than the maximum of 10"`, err.Error())
+		assert.Empty(t, dst.String())
+	})
+
+	t.Run("other validator", func(t *testing.T) {
+		var dst bytes.Buffer
+		validator := AllOf(SizeValidator(size, size), newSHA256Validator([]byte("bogus")))
+		_, err := CopyStreaming(&dst, loremBuf(t), validator, nil)
+		assert.Equal(t, &ValidatorError{msg: "checksum mismatch"}, err)
+		assert.Empty(t, dst.String())
+	})
+}
+
+func TestAnyOf(t *testing.T) {
+	loremSum := sha256.Sum256(loremBuf(t).Bytes())
+
+	t.Run("one passes", func(t *testing.T) {
+		var dst bytes.Buffer
+		validator := AnyOf(ChecksumValidator(crypto.SHA256, []byte("bogus")), ChecksumValidator(crypto.SHA256, loremSum[:]))
+		_, err := CopyStreaming(&dst, loremBuf(t), validator, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+	})
+
+	t.Run("none pass", func(t *testing.T) {
+		var dst bytes.Buffer
+		validator := AnyOf(SizeValidator(0, 10), newSHA256Validator([]byte("bogus")))
+		_, err := CopyStreaming(&dst, loremBuf(t), validator, nil)
+		assert.True(t, errors.Is(err, ErrValidationFailed))
+		assert.Equal(t, `validator returned false with the message: "none of the validators passed: size 86700 is more than the maximum of 10; checksum mismatch"`, err.Error())
+		assert.Empty(t, dst.String())
+	})
+}
+
+func TestValidators_reuse(t *testing.T) {
+	loremSum := sha256.Sum256(loremBuf(t).Bytes())
+	ks := &knownsums.KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	require.NoError(t, ks.Add("lorem", crypto.SHA256, loremBuf(t).Bytes()))
+	size := int64(loremBuf(t).Len())
+
+	for name, v := range map[string]StreamingValidator{
+		"ChecksumValidator":  ChecksumValidator(crypto.SHA256, loremSum[:]),
+		"KnownSumsValidator": KnownSumsValidator(ks, "lorem", nil),
+		"SizeValidator":      SizeValidator(size, size),
+		"AllOf":              AllOf(SizeValidator(size, size)),
+		"AnyOf":              AnyOf(SizeValidator(size, size)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var dst bytes.Buffer
+			_, err := CopyStreaming(&dst, loremBuf(t), v, nil)
+			require.NoError(t, err)
+
+			dst.Reset()
+			_, err = CopyStreaming(&dst, loremBuf(t), v, nil)
+			assert.Error(t, err)
+			assert.Empty(t, dst.String())
+
+			_, err = v.Write([]byte("EVIL"))
+			assert.Equal(t, ErrValidatorUsed, err)
+			ok, msg := v.Verify()
+			assert.False(t, ok)
+			assert.Equal(t, ErrValidatorUsed.Error(), msg)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package cachecopy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+//ErrWriterClosed is returned when writing to a ValidatingWriter that has been closed
+var ErrWriterClosed = errors.New("write to closed validating writer")
+
+//NewValidatingWriter returns an io.WriteCloser that writes to cache and validator. Close verifies validator and only
+//then copies the cached data to dst. When validation fails, Close returns a *ValidatorError and nothing is written to
+//dst. Close also closes cache, but it doesn't close dst.
+//When cache is nil, it uses NewSpillCache with DefaultMaxMemory.
+func NewValidatingWriter(dst io.Writer, validator StreamingValidator, cache Cache) io.WriteCloser {
+	if cache == nil {
+		cache = NewSpillCache(DefaultMaxMemory, "")
+	}
+	w := &validatingWriter{
+		dst:       dst,
+		validator: validator,
+		cache:     cache,
+	}
+	if validator == nil {
+		w.err = ErrNilValidator
+	}
+	return w
+}
+
+type validatingWriter struct {
+	dst       io.Writer
+	validator StreamingValidator
+	cache     Cache
+	//err is the first error from Write. Once it is set, nothing is written to dst.
+	err    error
+	closed bool
+}
+
+func (w *validatingWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.cache.Write(p)
+	if err != nil {
+		w.err = fmt.Errorf("error writing to cache: %w", err)
+		return n, w.err
+	}
+	_, err = w.validator.Write(p)
+	if err != nil {
+		w.err = fmt.Errorf("error writing to validator: %w", err)
+		return n, w.err
+	}
+	return n, nil
+}
+
+//Close verifies everything written and copies it to dst when it passes. Calling Close more than once returns
+//ErrWriterClosed.
+func (w *validatingWriter) Close() (err error) {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	w.closed = true
+	defer func() {
+		closeErr := w.cache.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	if w.validator == nil {
+		return w.err
+	}
+	validateErr := verifyErr(w.validator)
+	if w.err != nil {
+		return w.err
+	}
+	if validateErr != nil {
+		return validatorError(validateErr)
+	}
+	rdr, err := w.cache.Reader()
+	if err != nil {
+		return fmt.Errorf("error getting cache reader: %w", err)
+	}
+	defer func() {
+		_ = rdr.Close()
+	}()
+	_, err = io.Copy(w.dst, rdr)
+	return err
+}
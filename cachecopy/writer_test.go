@@ -0,0 +1,109 @@
+package cachecopy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//errWriter is an io.Writer that always fails
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("errWriter always fails")
+}
+
+func TestValidatingWriter(t *testing.T) {
+	loremSum := sha256.Sum256(loremBuf(t).Bytes())
+
+	t.Run("valid", func(t *testing.T) {
+		var dst bytes.Buffer
+		w := NewValidatingWriter(&dst, ChecksumValidator(crypto.SHA256, loremSum[:]), nil)
+		_, err := loremBuf(t).WriteTo(w)
+		require.NoError(t, err)
+		assert.Empty(t, dst.String())
+		assert.NoError(t, w.Close())
+		assert.Equal(t, loremBuf(t).String(), dst.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var buf bytes.Buffer
+		cache := NewBufferCache(&buf)
+		var dst bytes.Buffer
+		w := NewValidatingWriter(&dst, ChecksumValidator(crypto.SHA256, []byte("bogus")), cache)
+		_, err := loremBuf(t).WriteTo(w)
+		require.NoError(t, err)
+		err = w.Close()
+		var validatorErr *ValidatorError
+		assert.True(t, errors.As(err, &validatorErr))
+		assert.True(t, errors.Is(err, sumchecker.ErrMismatch))
+		assert.Empty(t, dst.String())
+		assert.Zero(t, cache.(*bufferCache).Len())
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		var dst bytes.Buffer
+		w := NewValidatingWriter(&dst, SizeValidator(1, -1), nil)
+		gz := gzip.NewWriter(w)
+		_, err := loremBuf(t).WriteTo(gz)
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		require.NoError(t, w.Close())
+		gr, err := gzip.NewReader(&dst)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(gr)
+		require.NoError(t, err)
+		assert.Equal(t, loremBuf(t).String(), string(got))
+	})
+
+	t.Run("write after close", func(t *testing.T) {
+		var dst bytes.Buffer
+		w := NewValidatingWriter(&dst, SizeValidator(0, -1), nil)
+		require.NoError(t, w.Close())
+		_, err := w.Write([]byte("foo"))
+		assert.Equal(t, ErrWriterClosed, err)
+		assert.Equal(t, ErrWriterClosed, w.Close())
+	})
+
+	t.Run("nil validator", func(t *testing.T) {
+		var dst bytes.Buffer
+		w := NewValidatingWriter(&dst, nil, nil)
+		_, err := w.Write([]byte("foo"))
+		assert.Equal(t, ErrNilValidator, err)
+		assert.Equal(t, ErrNilValidator, w.Close())
+		assert.Empty(t, dst.String())
+	})
+
+	t.Run("validator write error", func(t *testing.T) {
+		var dst bytes.Buffer
+		w := NewValidatingWriter(&dst, AllOf(SizeValidator(0, -1), &failingWriter{}), nil)
+		_, err := w.Write([]byte("foo"))
+		assert.Error(t, err)
+		assert.Equal(t, err, w.Close())
+		assert.Empty(t, dst.String())
+	})
+
+	t.Run("dst write error", func(t *testing.T) {
+		w := NewValidatingWriter(errWriter{}, SizeValidator(0, -1), nil)
+		_, err := w.Write([]byte("foo"))
+		require.NoError(t, err)
+		assert.EqualError(t, w.Close(), "errWriter always fails")
+	})
+}
+
+//failingWriter is a StreamingValidator whose Write always fails
+type failingWriter struct {
+	errWriter
+}
+
+func (*failingWriter) Verify() (bool, string) {
+	return true, ""
+}
@@ -2,19 +2,21 @@ package main
 
 import (
 	"crypto"
-	_ "crypto/md5"
-	_ "crypto/sha1"
-	_ "crypto/sha256"
-	_ "crypto/sha512"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/WillAbides/checksum/internal/exitcode"
 	"github.com/WillAbides/checksum/knownsums"
 	"github.com/WillAbides/checksum/knownsums/hashnames"
+	_ "github.com/WillAbides/checksum/knownsums/hashnames/all"
 	"github.com/WillAbides/checksum/sumchecker"
 	"github.com/alecthomas/kong"
 )
@@ -23,9 +25,11 @@ type existingChecksums struct {
 	Checksums string `kong:"required,type=existingfile,short='c',help='checksums file'"`
 }
 
-func (c existingChecksums) knownSums() (*knownsums.KnownSums, error) {
-	sums := knownsums.KnownSums{
-		Checker: sumchecker.New(nil),
+func (c existingChecksums) knownSums(key keyFile) (*knownsums.KnownSums, error) {
+	sums := knownsums.KnownSums{}
+	err := key.configure(&sums)
+	if err != nil {
+		return &sums, err
 	}
 	b, err := ioutil.ReadFile(c.Checksums)
 	if err != nil {
@@ -38,10 +42,54 @@ func (c existingChecksums) knownSums() (*knownsums.KnownSums, error) {
 	return &sums, err
 }
 
+type keyFile struct {
+	KeyFile string `kong:"type=existingfile,help='file containing the key for keyed (HMAC) checksums'"`
+}
+
+//configure sets sums' Checker and KeyID for the key in KeyFile
+func (k keyFile) configure(sums *knownsums.KnownSums) error {
+	if k.KeyFile == "" {
+		sums.Checker = sumchecker.New(nil)
+		return nil
+	}
+	key, err := ioutil.ReadFile(k.KeyFile)
+	if err != nil {
+		return err
+	}
+	sums.Checker = sumchecker.New(sumchecker.NewHMACRunner(key), sumchecker.WithConstantTimeCompare())
+	sums.KeyID = sumchecker.KeyID(key)
+	return nil
+}
+
+type unavailablePolicy struct {
+	Unavailable string `kong:"enum='skip,fail,require-strong',default='skip',help='How to treat checksums whose algorithm is not available. One of skip, fail or require-strong.'"`
+}
+
+//configure sets sums' UnavailablePolicy
+func (u unavailablePolicy) configure(sums *knownsums.KnownSums) {
+	policy, _ := knownsums.LookupUnavailablePolicy(u.Unavailable)
+	sums.UnavailablePolicy = policy
+}
+
+type minStrength struct {
+	MinStrength string `kong:"enum='broken,weak,strong',default='broken',help='Refuse to add or trust checksums from algorithms weaker than this. One of broken, weak or strong.'"`
+}
+
+//configure sets sums' MinStrength
+func (m minStrength) configure(sums *knownsums.KnownSums) {
+	strength, _ := sumchecker.LookupStrength(m.MinStrength)
+	sums.MinStrength = strength
+}
+
 type mainCmd struct {
 	Add      addCmd      `kong:"cmd"`
 	Validate validateCmd `kong:"cmd"`
 	Init     initCmd     `kong:"cmd"`
+	Import   importCmd   `kong:"cmd,help='Import a sha256sum (gnu) or BSD style manifest into the checksums file.'"`
+	Export   exportCmd   `kong:"cmd,help='Write the checksums file as a sha256sum (gnu) or BSD style manifest.'"`
+	Check    checkCmd    `kong:"cmd,help='Validate every file in the checksums file with all of its algorithms.'"`
+	Update   updateCmd   `kong:"cmd,help='Add a checksum, replacing any existing checksum with the same name and algorithm.'"`
+	List     listCmd     `kong:"cmd,help='List the checksums in the checksums file.'"`
 }
 
 type initCmd struct {
@@ -74,6 +122,7 @@ type nameFileAlgo struct {
 	File      string `kong:"arg,existingfile"`
 	Name      string `kong:"arg,optional"`
 	Algorithm string `kong:"short=a,enum=${algo_enum},default=${algo_default},help=${algo_help}"`
+	Dir       bool   `kong:"help='FILE is a directory to pin with a single checksum of all its files'"`
 }
 
 func (n nameFileAlgo) hash() crypto.Hash {
@@ -90,11 +139,37 @@ func (n nameFileAlgo) name() string {
 type addCmd struct {
 	NameFileAlgo      nameFileAlgo      `kong:"embed"`
 	ExistingChecksums existingChecksums `kong:"embed"`
+	KeyFile           keyFile           `kong:"embed"`
+	ChunkSize         int               `kong:"help='also store the checksum of each chunk of this many bytes so byte ranges can be validated'"`
+	Recursive         recursiveOpts     `kong:"embed"`
+	Force             bool              `kong:"short=f,help='replace existing checksums with the same name and algorithm'"`
+	MinStrength       minStrength       `kong:"embed"`
+}
+
+type updateCmd struct {
+	NameFileAlgo      nameFileAlgo      `kong:"embed"`
+	ExistingChecksums existingChecksums `kong:"embed"`
+	KeyFile           keyFile           `kong:"embed"`
+	MinStrength       minStrength       `kong:"embed"`
+}
+
+func (c *updateCmd) Run() error {
+	add := addCmd{
+		NameFileAlgo:      c.NameFileAlgo,
+		ExistingChecksums: c.ExistingChecksums,
+		KeyFile:           c.KeyFile,
+		MinStrength:       c.MinStrength,
+		Force:             true,
+	}
+	return add.Run()
 }
 
 type validateCmd struct {
 	NameFileAlgo      nameFileAlgo      `kong:"embed"`
 	ExistingChecksums existingChecksums `kong:"embed"`
+	KeyFile           keyFile           `kong:"embed"`
+	UnavailablePolicy unavailablePolicy `kong:"embed"`
+	MinStrength       minStrength       `kong:"embed"`
 }
 
 func writeKnownSumsToFile(sums *knownsums.KnownSums, filename string) error {
@@ -106,15 +181,49 @@ func writeKnownSumsToFile(sums *knownsums.KnownSums, filename string) error {
 }
 
 func (c *addCmd) Run() error {
-	checksums, err := c.ExistingChecksums.knownSums()
+	checksums, err := c.ExistingChecksums.knownSums(c.KeyFile)
 	if err != nil {
 		return err
 	}
-	data, err := ioutil.ReadFile(c.NameFileAlgo.File)
+	c.MinStrength.configure(checksums)
+	if c.Force && (c.NameFileAlgo.Dir || c.ChunkSize > 0) {
+		return fmt.Errorf("--force cannot be used with --dir or --chunk-size")
+	}
+	if c.NameFileAlgo.Dir {
+		if c.Recursive.Recursive || c.ChunkSize > 0 {
+			return fmt.Errorf("--dir cannot be used with --recursive or --chunk-size")
+		}
+		err = checksums.AddDir(c.NameFileAlgo.name(), c.NameFileAlgo.hash(), c.NameFileAlgo.File)
+		if err != nil {
+			return err
+		}
+		return writeKnownSumsToFile(checksums, c.ExistingChecksums.Checksums)
+	}
+	if c.Recursive.Recursive {
+		if c.ChunkSize > 0 {
+			return fmt.Errorf("--chunk-size cannot be used with --recursive")
+		}
+		err = c.Recursive.addDir(checksums, c.NameFileAlgo, c.Force)
+		if err != nil {
+			return err
+		}
+		return writeKnownSumsToFile(checksums, c.ExistingChecksums.Checksums)
+	}
+	file, err := os.Open(c.NameFileAlgo.File)
 	if err != nil {
 		return err
 	}
-	err = checksums.Add(c.NameFileAlgo.name(), c.NameFileAlgo.hash(), data)
+	defer func() {
+		_ = file.Close()
+	}()
+	switch {
+	case c.ChunkSize > 0:
+		err = checksums.AddChunked(c.NameFileAlgo.name(), c.NameFileAlgo.hash(), c.ChunkSize, file)
+	case c.Force:
+		_, err = checksums.UpsertReader(c.NameFileAlgo.name(), c.NameFileAlgo.hash(), file)
+	default:
+		err = checksums.AddReader(c.NameFileAlgo.name(), c.NameFileAlgo.hash(), file)
+	}
 	if err != nil {
 		return err
 	}
@@ -122,25 +231,280 @@ func (c *addCmd) Run() error {
 }
 
 func (c *validateCmd) Run() error {
-	checksums, err := c.ExistingChecksums.knownSums()
+	checksums, err := c.ExistingChecksums.knownSums(c.KeyFile)
 	if err != nil {
 		return err
 	}
-	data, err := ioutil.ReadFile(c.NameFileAlgo.File)
+	c.MinStrength.configure(checksums)
+	c.UnavailablePolicy.configure(checksums)
+	if c.NameFileAlgo.Dir {
+		hsh := c.NameFileAlgo.hash()
+		got, diff, err := checksums.ValidateDir(c.NameFileAlgo.name(), &hsh, c.NameFileAlgo.File)
+		if err != nil {
+			return err
+		}
+		if !got {
+			printDirDiff(diff)
+			return fmt.Errorf("checksum for %s did not match: %w", c.NameFileAlgo.File, knownsums.ErrMismatch)
+		}
+		return nil
+	}
+	file, err := os.Open(c.NameFileAlgo.File)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = file.Close()
+	}()
 	hsh := c.NameFileAlgo.hash()
-	got, err := checksums.Validate(c.NameFileAlgo.name(), &hsh, data)
+	result, err := checksums.ValidateDetailed(c.NameFileAlgo.name(), &hsh, file)
 	if err != nil {
 		return err
 	}
-	if !got {
-		return fmt.Errorf("checksum for %s did not match", c.NameFileAlgo.File)
+	printValidationResult(result)
+	err = result.Err()
+	if err != nil {
+		return fmt.Errorf("error validating %s: %w", c.NameFileAlgo.File, err)
 	}
 	return nil
 }
 
+func printValidationResult(result *knownsums.ValidationResult) {
+	for _, entry := range result.Entries {
+		fmt.Printf("%s %s: %s\n", result.Name, hashName(entry.Hash, entry.HashName), entry.Status)
+		if entry.Status == knownsums.StatusMismatched {
+			fmt.Printf("  expected: %x\n  actual:   %x\n", entry.Expected, entry.Actual)
+		}
+	}
+}
+
+type importCmd struct {
+	Manifest          string            `kong:"arg,type=existingfile,help='manifest to import'"`
+	Format            string            `kong:"short=f,enum=${format_enum},default=gnu,help=${format_help}"`
+	Algorithm         string            `kong:"short=a,enum=${algo_enum},default=${algo_default},help='The hash algorithm used in a gnu manifest.'"`
+	ExistingChecksums existingChecksums `kong:"embed"`
+	KeyFile           keyFile           `kong:"embed"`
+	MinStrength       minStrength       `kong:"embed"`
+}
+
+func (c *importCmd) Run() error {
+	checksums, err := c.ExistingChecksums.knownSums(c.KeyFile)
+	if err != nil {
+		return err
+	}
+	c.MinStrength.configure(checksums)
+	file, err := os.Open(c.Manifest)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	switch c.Format {
+	case "bsd":
+		err = checksums.ImportBSD(file)
+	default:
+		err = checksums.ImportCoreutils(file, hashnames.LookupHash(c.Algorithm))
+	}
+	if err != nil {
+		return fmt.Errorf("error importing %s: %w", c.Manifest, err)
+	}
+	return writeKnownSumsToFile(checksums, c.ExistingChecksums.Checksums)
+}
+
+type exportCmd struct {
+	Format            string            `kong:"short=f,enum=${format_enum},default=gnu,help=${format_help}"`
+	Algorithm         string            `kong:"short=a,help='The hash algorithm to export. gnu manifests default to sha256. bsd manifests default to all algorithms.'"`
+	ExistingChecksums existingChecksums `kong:"embed"`
+	KeyFile           keyFile           `kong:"embed"`
+}
+
+func (c *exportCmd) Run() error {
+	checksums, err := c.ExistingChecksums.knownSums(c.KeyFile)
+	if err != nil {
+		return err
+	}
+	var hsh *crypto.Hash
+	if c.Algorithm != "" {
+		h := hashnames.LookupHash(c.Algorithm)
+		if h == 0 {
+			return fmt.Errorf("unknown algorithm %q", c.Algorithm)
+		}
+		hsh = &h
+	}
+	if c.Format == "bsd" {
+		return checksums.ExportBSD(os.Stdout, hsh)
+	}
+	if hsh == nil {
+		h := crypto.SHA256
+		hsh = &h
+	}
+	return checksums.ExportCoreutils(os.Stdout, *hsh)
+}
+
+type checkCmd struct {
+	Root              string            `kong:"type=existingdir,default='.',help='directory that checksum names are relative to'"`
+	ExistingChecksums existingChecksums `kong:"embed"`
+	KeyFile           keyFile           `kong:"embed"`
+	UnavailablePolicy unavailablePolicy `kong:"embed"`
+	MinStrength       minStrength       `kong:"embed"`
+}
+
+func (c *checkCmd) Run() error {
+	checksums, err := c.ExistingChecksums.knownSums(c.KeyFile)
+	if err != nil {
+		return err
+	}
+	c.MinStrength.configure(checksums)
+	c.UnavailablePolicy.configure(checksums)
+	names := checksums.Names()
+	var failed, missing int
+	for _, name := range names {
+		status, err := c.check(checksums, name)
+		switch status {
+		case "FAILED":
+			failed++
+		case "MISSING":
+			missing++
+		}
+		fmt.Printf("%s: %s\n", name, status)
+		if err != nil {
+			errOut("%s: %v\n", name, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed validation and %d were missing: %w", failed, len(names), missing, knownsums.ErrMismatch)
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d of %d files were missing", missing, len(names))
+	}
+	return nil
+}
+
+func printDirDiff(diff *knownsums.DirDiff) {
+	if diff == nil {
+		return
+	}
+	for _, name := range diff.Added {
+		errOut("added: %s\n", name)
+	}
+	for _, name := range diff.Removed {
+		errOut("removed: %s\n", name)
+	}
+	for _, name := range diff.Changed {
+		errOut("changed: %s\n", name)
+	}
+}
+
+//hashName returns unknownName for checksums loaded with a hash name that this binary doesn't know
+func hashName(hash crypto.Hash, unknownName string) string {
+	if hash == 0 && unknownName != "" {
+		return unknownName
+	}
+	return hashnames.HashName(hash)
+}
+
+func errOut(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stderr, format, a...)
+}
+
+func (c *checkCmd) check(checksums *knownsums.KnownSums, name string) (string, error) {
+	filename := filepath.Join(c.Root, filepath.FromSlash(name))
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return "MISSING", nil
+	}
+	if err != nil {
+		return "FAILED", err
+	}
+	if info.IsDir() {
+		ok, diff, err := checksums.ValidateDir(name, nil, filename)
+		if err != nil || !ok {
+			printDirDiff(diff)
+			return "FAILED", err
+		}
+		return "OK", nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return "FAILED", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	result, err := checksums.ValidateDetailed(name, nil, file)
+	if err != nil {
+		return "FAILED", err
+	}
+	for _, skipped := range result.Skipped() {
+		errOut("%s: skipped %s because it is not available\n", name, hashName(skipped.Hash, skipped.HashName))
+	}
+	for _, untrusted := range result.Untrusted() {
+		errOut("%s: skipped %s because it is weaker than --min-strength\n", name, hashName(untrusted.Hash, untrusted.HashName))
+	}
+	if !result.OK() {
+		return "FAILED", result.Err()
+	}
+	return "OK", nil
+}
+
+type listCmd struct {
+	Pattern           string            `kong:"arg,optional,help='only list names matching this glob'"`
+	Algorithm         string            `kong:"short=a,help='only list checksums using this algorithm'"`
+	JSON              bool              `kong:"help='output JSON instead of a table'"`
+	ExistingChecksums existingChecksums `kong:"embed"`
+}
+
+func (c *listCmd) Run() error {
+	checksums, err := c.ExistingChecksums.knownSums(keyFile{})
+	if err != nil {
+		return err
+	}
+	var hsh *crypto.Hash
+	//unknownHashName lists checksums that were saved with an algorithm this binary doesn't know
+	var unknownHashName string
+	if c.Algorithm != "" {
+		h := hashnames.LookupHash(c.Algorithm)
+		if h == 0 {
+			unknownHashName = c.Algorithm
+		}
+		hsh = &h
+	}
+	entries := []knownsums.Entry{}
+	var matchErr error
+	checksums.Each(hsh, func(entry knownsums.Entry) bool {
+		if unknownHashName != "" && entry.HashName != unknownHashName {
+			return true
+		}
+		if c.Pattern != "" {
+			var ok bool
+			ok, matchErr = path.Match(c.Pattern, entry.Name)
+			if matchErr != nil || !ok {
+				return matchErr == nil
+			}
+		}
+		entries = append(entries, entry)
+		return true
+	})
+	if matchErr != nil {
+		return fmt.Errorf("invalid glob %q: %w", c.Pattern, matchErr)
+	}
+	if c.JSON {
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(b))
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tALGORITHM\tCHECKSUM\tKEY ID")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%x\t%s\n", entry.Name, hashName(entry.Hash, entry.HashName), entry.Checksum, entry.KeyID)
+	}
+	return tw.Flush()
+}
+
 var cli mainCmd
 
 func main() {
@@ -148,8 +512,19 @@ func main() {
 		"algo_enum":    strings.Join(hashnames.AvailableHashNames(), ","),
 		"algo_default": hashnames.HashName(crypto.SHA256),
 		"algo_help":    fmt.Sprintf("The hash algorithm to use.  One of %s", strings.Join(hashnames.AvailableHashNames(), ", ")),
+		"format_enum":  "gnu,bsd",
+		"jobs_default": strconv.Itoa(runtime.NumCPU()),
+		"format_help":  `Manifest format. gnu is "<hex>  <name>" like sha256sum. bsd is "SHA256 (<name>) = <hex>".`,
+	}
+	parser := kong.Must(&cli, vars)
+	kctx, err := parser.Parse(os.Args[1:])
+	if err != nil {
+		parser.Errorf("%s", err)
+		parser.Exit(exitcode.Usage)
+	}
+	err = kctx.Run()
+	if err != nil {
+		kctx.Errorf("%s", err)
+		kctx.Exit(exitcode.Code(err))
 	}
-	kctx := kong.Parse(&cli, vars)
-	err := kctx.Run()
-	kctx.FatalIfErrorf(err)
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/WillAbides/checksum/knownsums"
+	"github.com/WillAbides/checksum/sumchecker"
+)
+
+type recursiveOpts struct {
+	Recursive bool     `kong:"short=r,help='add every file in the directory FILE named by its slash-separated path relative to FILE. NAME is used as a prefix.'"`
+	Include   []string `kong:"help='with --recursive, only add files whose relative path or base name matches one of these globs'"`
+	Exclude   []string `kong:"help='with --recursive, skip files and directories whose relative path or base name matches one of these globs'"`
+	Jobs      int      `kong:"short=j,default=${jobs_default},help='number of files to hash at once with --recursive'"`
+}
+
+//matchAny returns true if relPath or its base name matches any of patterns
+func matchAny(patterns []string, relPath string) (bool, error) {
+	for _, pattern := range patterns {
+		for _, name := range []string{relPath, path.Base(relPath)} {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				return false, fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+			if ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+//walkFiles returns the slash-separated paths relative to root of the regular files under root that pass the include
+//and exclude globs in lexical order
+func (r recursiveOpts) walkFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, filename)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		excluded, err := matchAny(r.Exclude, rel)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if excluded {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if excluded || !info.Mode().IsRegular() {
+			return nil
+		}
+		if len(r.Include) > 0 {
+			included, err := matchAny(r.Include, rel)
+			if err != nil || !included {
+				return err
+			}
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+//addDir hashes every file in nfa.File with a pool of r.Jobs workers and adds them to checksums.
+//When force is true, existing checksums are replaced. Otherwise nothing is added if any of the names is a duplicate.
+func (r recursiveOpts) addDir(checksums *knownsums.KnownSums, nfa nameFileAlgo, force bool) error {
+	info, err := os.Stat(nfa.File)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", nfa.File)
+	}
+	files, err := r.walkFiles(nfa.File)
+	if err != nil {
+		return err
+	}
+	hsh := nfa.hash()
+	err = sumchecker.CheckStrength(hsh, checksums.MinStrength)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(files))
+	for idx, rel := range files {
+		names[idx] = rel
+		if nfa.Name != "" {
+			names[idx] = path.Join(nfa.Name, rel)
+		}
+		if !force && hasSum(checksums, names[idx], hsh) {
+			return fmt.Errorf("error adding %s: %w", names[idx], knownsums.ErrDuplicate)
+		}
+	}
+	sums := make([][]byte, len(files))
+	errs := make([]error, len(files))
+	jobs := make(chan int)
+	workers := r.Jobs
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				sums[idx], errs[idx] = checksumFile(checksums.Checker, hsh, filepath.Join(nfa.File, filepath.FromSlash(files[idx])))
+			}
+		}()
+	}
+	for idx := range files {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	for idx, rel := range files {
+		if errs[idx] != nil {
+			return fmt.Errorf("error calculating sum for %s: %w", rel, errs[idx])
+		}
+	}
+	for idx, name := range names {
+		if force {
+			_, err = checksums.Set(name, hsh, sums[idx])
+		} else {
+			err = checksums.AddPrecalculatedSum(name, hsh, sums[idx])
+		}
+		if err != nil {
+			return fmt.Errorf("error adding %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+//hasSum returns true if checksums already has a sum for name and hsh with its KeyID
+func hasSum(checksums *knownsums.KnownSums, name string, hsh crypto.Hash) bool {
+	for _, entry := range checksums.Lookup(name) {
+		if entry.Hash == hsh && entry.KeyID == checksums.KeyID {
+			return true
+		}
+	}
+	return false
+}
+
+func checksumFile(checker knownsums.Checker, hsh crypto.Hash, filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return checker.ChecksumReader(hsh, file)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/WillAbides/checksum/knownsums"
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tmpTree(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0750))
+		require.NoError(t, ioutil.WriteFile(filename, []byte(content), 0640))
+	}
+	return dir, func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+var testTree = map[string]string{
+	"a.txt":           "a",
+	"b.go":            "b",
+	"sub/c.txt":       "c",
+	"sub/d.go":        "d",
+	"sub/vendor/e.go": "e",
+	"vendor/f.go":     "f",
+}
+
+func TestMatchAny(t *testing.T) {
+	for _, td := range []struct {
+		name     string
+		patterns []string
+		relPath  string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "no patterns", relPath: "a.txt"},
+		{name: "base name", patterns: []string{"*.txt"}, relPath: "sub/c.txt", want: true},
+		{name: "relative path", patterns: []string{"sub/*.txt"}, relPath: "sub/c.txt", want: true},
+		{name: "star doesn't cross slashes", patterns: []string{"*/c.txt"}, relPath: "sub/sub/c.txt"},
+		{name: "no match", patterns: []string{"*.go", "sub/*.md"}, relPath: "sub/c.txt"},
+		{name: "second pattern", patterns: []string{"*.go", "c.*"}, relPath: "sub/c.txt", want: true},
+		{name: "invalid glob", patterns: []string{"["}, relPath: "a.txt", wantErr: true},
+	} {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			got, err := matchAny(td.patterns, td.relPath)
+			if td.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, td.want, got)
+		})
+	}
+}
+
+func TestRecursiveOpts_walkFiles(t *testing.T) {
+	dir, teardown := tmpTree(t, testTree)
+	defer teardown()
+	for _, td := range []struct {
+		name    string
+		opts    recursiveOpts
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "all",
+			want: []string{"a.txt", "b.go", "sub/c.txt", "sub/d.go", "sub/vendor/e.go", "vendor/f.go"},
+		},
+		{
+			name: "include base name",
+			opts: recursiveOpts{Include: []string{"*.go"}},
+			want: []string{"b.go", "sub/d.go", "sub/vendor/e.go", "vendor/f.go"},
+		},
+		{
+			name: "include relative path",
+			opts: recursiveOpts{Include: []string{"sub/*"}},
+			want: []string{"sub/c.txt", "sub/d.go"},
+		},
+		{
+			name: "exclude base name",
+			opts: recursiveOpts{Exclude: []string{"*.txt"}},
+			want: []string{"b.go", "sub/d.go", "sub/vendor/e.go", "vendor/f.go"},
+		},
+		{
+			name: "exclude dir by base name",
+			opts: recursiveOpts{Exclude: []string{"vendor"}},
+			want: []string{"a.txt", "b.go", "sub/c.txt", "sub/d.go"},
+		},
+		{
+			name: "exclude dir by relative path",
+			opts: recursiveOpts{Exclude: []string{"sub/vendor"}},
+			want: []string{"a.txt", "b.go", "sub/c.txt", "sub/d.go", "vendor/f.go"},
+		},
+		{
+			name: "exclude wins over include",
+			opts: recursiveOpts{Include: []string{"*.go"}, Exclude: []string{"vendor"}},
+			want: []string{"b.go", "sub/d.go"},
+		},
+		{
+			name:    "invalid glob",
+			opts:    recursiveOpts{Exclude: []string{"["}},
+			wantErr: true,
+		},
+	} {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			got, err := td.opts.walkFiles(dir)
+			if td.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, td.want, got)
+		})
+	}
+}
+
+func TestRecursiveOpts_addDir(t *testing.T) {
+	dir, teardown := tmpTree(t, testTree)
+	defer teardown()
+	newKnownSums := func() *knownsums.KnownSums {
+		return &knownsums.KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+	}
+	sha256 := crypto.SHA256
+
+	for _, td := range []struct {
+		name      string
+		prefix    string
+		jobs      int
+		wantNames []string
+	}{
+		{
+			name:      "no prefix",
+			jobs:      1,
+			wantNames: []string{"a.txt", "b.go", "sub/c.txt", "sub/d.go", "sub/vendor/e.go", "vendor/f.go"},
+		},
+		{
+			name:      "prefix",
+			prefix:    "pre",
+			jobs:      4,
+			wantNames: []string{"pre/a.txt", "pre/b.go", "pre/sub/c.txt", "pre/sub/d.go", "pre/sub/vendor/e.go", "pre/vendor/f.go"},
+		},
+		{
+			name:      "zero jobs",
+			jobs:      0,
+			wantNames: []string{"a.txt", "b.go", "sub/c.txt", "sub/d.go", "sub/vendor/e.go", "vendor/f.go"},
+		},
+	} {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			checksums := newKnownSums()
+			opts := recursiveOpts{Jobs: td.jobs}
+			err := opts.addDir(checksums, nameFileAlgo{File: dir, Name: td.prefix, Algorithm: "sha256"}, false)
+			require.NoError(t, err)
+			assert.Equal(t, td.wantNames, checksums.Names())
+			for i, name := range td.wantNames {
+				content := testTree[strings.TrimPrefix(name, td.prefix+"/")]
+				ok, err := checksums.Validate(name, &sha256, []byte(content))
+				assert.NoError(t, err)
+				assert.Truef(t, ok, "checksum %d for %s", i, name)
+			}
+		})
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		checksums := newKnownSums()
+		require.NoError(t, checksums.Add("pre/sub/d.go", crypto.SHA256, []byte("old")))
+		opts := recursiveOpts{Jobs: 2}
+		err := opts.addDir(checksums, nameFileAlgo{File: dir, Name: "pre", Algorithm: "sha256"}, false)
+		assert.True(t, errors.Is(err, knownsums.ErrDuplicate))
+		assert.Equal(t, []string{"pre/sub/d.go"}, checksums.Names())
+		ok, err := checksums.Validate("pre/sub/d.go", &sha256, []byte("old"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("duplicate with another hash", func(t *testing.T) {
+		checksums := newKnownSums()
+		require.NoError(t, checksums.Add("sub/d.go", crypto.SHA512, []byte("old")))
+		opts := recursiveOpts{Jobs: 2}
+		err := opts.addDir(checksums, nameFileAlgo{File: dir, Algorithm: "sha256"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, 7, checksums.Len())
+	})
+
+	t.Run("force", func(t *testing.T) {
+		checksums := newKnownSums()
+		require.NoError(t, checksums.Add("pre/sub/d.go", crypto.SHA256, []byte("old")))
+		opts := recursiveOpts{Jobs: 2}
+		err := opts.addDir(checksums, nameFileAlgo{File: dir, Name: "pre", Algorithm: "sha256"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, 6, checksums.Len())
+		ok, err := checksums.Validate("pre/sub/d.go", &sha256, []byte("d"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		checksums := newKnownSums()
+		opts := recursiveOpts{Jobs: 1}
+		err := opts.addDir(checksums, nameFileAlgo{File: filepath.Join(dir, "a.txt"), Algorithm: "sha256"}, false)
+		assert.Error(t, err)
+		assert.Equal(t, 0, checksums.Len())
+	})
+}
@@ -1,20 +1,18 @@
 package main
 
 import (
-	_ "crypto/md5"
-	_ "crypto/sha1"
-	_ "crypto/sha256"
-	_ "crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/WillAbides/checksum/cachecopy"
+	"github.com/WillAbides/checksum/internal/exitcode"
 	"github.com/WillAbides/checksum/knownsums/hashnames"
+	_ "github.com/WillAbides/checksum/knownsums/hashnames/all"
 	"github.com/WillAbides/checksum/sumchecker"
 )
 
@@ -23,20 +21,31 @@ func errOut(format string, a ...interface{}) {
 }
 
 func exitErr(format string, a ...interface{}) {
+	exitCode(exitcode.Failure, format, a...)
+}
+
+func exitCode(code int, format string, a ...interface{}) {
 	errOut(format, a...)
-	os.Exit(1)
+	os.Exit(code)
 }
 
 func main() {
 	var hashName string
+	var keyFile string
+	var minStrengthName string
+	var maxMemory int64
 
-	flag.StringVar(&hashName, "a", "sha256", "Hash algorithm to use.  One of sha1, sha256, sha512 or md5.")
+	flag.StringVar(&hashName, "a", "sha256", "Hash algorithm to use.  A sha2, sha3 or blake2 hash like sha256, sha3_256 or blake2b_512, one of md4, md5, sha1, ripemd160, blake3, crc32c or xxh64, or a tree hash like tree-sha256-1MiB.")
+	flag.StringVar(&keyFile, "key-file", "", "File containing the key for a keyed (HMAC) checksum.")
+	flag.Int64Var(&maxMemory, "max-memory", cachecopy.DefaultMaxMemory, "Bytes of input to hold in memory before spilling to a temp file.")
+	flag.StringVar(&minStrengthName, "min-strength", "broken", "Refuse hash algorithms weaker than this.  One of broken, weak or strong.")
 
 	flag.Usage = func() {
 		errOut(`
 safetyvalve reads from stdin, verifies that data received matched the given 
 checksum, then writes to stdout. When the checksum does not match, safetyvalve 
-returns 1 and writes nothing to stdout.
+returns 3 and writes nothing to stdout. Other errors return 1, or 5 when the 
+hash algorithm is not available, or 7 when it is weaker than -min-strength.
 
 Usage of %s:
 
@@ -52,7 +61,7 @@ Options:
 
 	if flag.NArg() != 1 {
 		flag.Usage()
-		os.Exit(2)
+		os.Exit(exitcode.Usage)
 	}
 
 	info, err := os.Stdin.Stat()
@@ -63,40 +72,44 @@ Options:
 	if info.Mode()&os.ModeCharDevice != 0 {
 		flag.Usage()
 		errOut("\n\n¡nothing piped to stdin!\n")
-		os.Exit(2)
+		os.Exit(exitcode.Usage)
 	}
 
 	wantSum, err := hex.DecodeString(flag.Arg(0))
 	if err != nil {
 		exitErr("checksum must be a hex value\n")
 	}
+	minStrength, ok := sumchecker.LookupStrength(minStrengthName)
+	if !ok {
+		exitCode(exitcode.Usage, "min-strength must be one of broken, weak or strong\n")
+	}
 	hsh := hashnames.LookupHash(hashName)
-	var validated bool
+	var runner sumchecker.HashRunner
+	if keyFile != "" {
+		key, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			exitErr("error reading key file: %v\n", err)
+		}
+		runner = sumchecker.NewHMACRunner(key)
+	}
+	checker := sumchecker.New(runner, sumchecker.WithConstantTimeCompare(), sumchecker.WithMinStrength(minStrength))
 
 	copier := &cachecopy.Copier{
-		Cache: cachecopy.NewBufferCache(nil),
-		Validator: func(rdr io.Reader) (bool, string) {
-			b, err := ioutil.ReadAll(rdr)
-			if err != nil {
-				exitErr("error reading input stream: %v\n", err)
-				return false, ""
-			}
-			got, err := sumchecker.ValidateChecksum(hsh, wantSum, b)
-			if err != nil {
-				exitErr("error validating the checksum: %v\n", err)
-				return false, ""
-			}
-			validated = got
-			return got, ""
+		Cache: cachecopy.NewSpillCache(maxMemory, ""),
+		NewStreamingValidator: func() cachecopy.StreamingValidator {
+			return cachecopy.CheckerValidator(checker, hsh, wantSum)
 		},
 	}
 
 	_, err = copier.Copy(os.Stdout, os.Stdin)
-	if err != nil {
-		exitErr("error copying to stdout: %v\n", err)
+	if errors.Is(err, sumchecker.ErrMismatch) {
+		exitCode(exitcode.Mismatch, "input did not match the checksum %x using the hash algorithm %s\n", wantSum, hashName)
 	}
-
-	if !validated {
-		exitErr("input did not match the checksum %x using the hash algorithm %s\n", wantSum, hashName)
+	var validatorErr *cachecopy.ValidatorError
+	if errors.As(err, &validatorErr) {
+		exitCode(exitcode.Code(err), "error validating the checksum: %v\n", validatorErr.Unwrap())
+	}
+	if err != nil {
+		exitCode(exitcode.Code(err), "error copying to stdout: %v\n", err)
 	}
 }
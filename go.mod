@@ -4,5 +4,8 @@ go 1.13
 
 require (
 	github.com/alecthomas/kong v0.2.1
+	github.com/cespare/xxhash/v2 v2.1.2
 	github.com/stretchr/testify v1.4.0
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	lukechampine.com/blake3 v1.1.7
 )
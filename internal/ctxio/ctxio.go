@@ -0,0 +1,28 @@
+//Package ctxio provides io helpers that stop when a context is done.
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+//NewReader returns an io.Reader that reads from r until ctx is done. Once ctx is done, Read returns ctx.Err().
+func NewReader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{
+		ctx: ctx,
+		r:   r,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	err := r.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,49 @@
+//Package exitcode maps the errors returned by this module's packages to the exit codes used by its commands.
+package exitcode
+
+import (
+	"errors"
+
+	"github.com/WillAbides/checksum/cachecopy"
+	"github.com/WillAbides/checksum/knownsums"
+	"github.com/WillAbides/checksum/sumchecker"
+)
+
+//Exit codes shared by the commands
+const (
+	OK = iota
+	//Failure is used for errors that don't have a more specific code
+	Failure
+	//Usage is used when the command line is invalid
+	Usage
+	//Mismatch is used when a checksum doesn't match
+	Mismatch
+	//NotFound is used when there is no known checksum to validate against
+	NotFound
+	//UnavailableHash is used when a hash algorithm isn't available
+	UnavailableHash
+	//Duplicate is used when adding a checksum that already exists
+	Duplicate
+	//Weak is used when a hash is weaker than the minimum strength
+	Weak
+)
+
+//Code returns the exit code for err
+func Code(err error) int {
+	switch {
+	case err == nil:
+		return OK
+	case errors.Is(err, knownsums.ErrNotFound):
+		return NotFound
+	case errors.Is(err, knownsums.ErrUnavailableHash), errors.Is(err, sumchecker.ErrUnregisteredHash):
+		return UnavailableHash
+	case errors.Is(err, knownsums.ErrDuplicate):
+		return Duplicate
+	case errors.Is(err, sumchecker.ErrWeakHash), errors.Is(err, knownsums.ErrNoStrongMatch):
+		return Weak
+	case errors.Is(err, sumchecker.ErrMismatch), errors.Is(err, cachecopy.ErrValidationFailed):
+		return Mismatch
+	default:
+		return Failure
+	}
+}
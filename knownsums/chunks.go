@@ -0,0 +1,165 @@
+package knownsums
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+)
+
+//chunkSummer is an io.Writer that calculates the checksum of each chunkSize chunk written to it
+type chunkSummer struct {
+	checker   Checker
+	hash      crypto.Hash
+	chunkSize int
+	buf       []byte
+	size      int64
+	sums      [][]byte
+}
+
+func (w *chunkSummer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		free := w.chunkSize - len(w.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		w.buf = append(w.buf, p[:free]...)
+		p = p[free:]
+		if len(w.buf) == w.chunkSize {
+			err := w.flush()
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+//flush adds the checksum of any buffered data to sums
+func (w *chunkSummer) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	sum, err := w.checker.ChecksumReader(w.hash, bytes.NewReader(w.buf))
+	if err != nil {
+		return err
+	}
+	w.size += int64(len(w.buf))
+	w.sums = append(w.sums, sum)
+	w.buf = w.buf[:0]
+	return nil
+}
+
+//AddChunked is like AddReader but also stores the checksum of each chunkSize chunk of r so that ranges of the data
+//can be validated with ValidateRange. The whole checksum and the chunk checksums are calculated in a single pass.
+func (c *KnownSums) AddChunked(name string, hash crypto.Hash, chunkSize int, r io.Reader) error {
+	if c.Checker == nil {
+		return ErrNilChecker
+	}
+	err := c.checkHash(hash)
+	if err != nil {
+		return err
+	}
+	if chunkSize <= 0 {
+		return fmt.Errorf("chunk size must be positive")
+	}
+	chunks := &chunkSummer{
+		checker:   c.Checker,
+		hash:      hash,
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize),
+	}
+	sum, err := c.Checker.ChecksumReader(hash, io.TeeReader(r, chunks))
+	if err == nil {
+		err = chunks.flush()
+	}
+	if err != nil {
+		return fmt.Errorf("error calculating sum: %w", err)
+	}
+	return c.addKnownSum(&knownSum{
+		Name:      name,
+		Hash:      hash,
+		Checksum:  sum,
+		Size:      chunks.size,
+		ChunkSize: chunkSize,
+		Chunks:    chunks.sums,
+	})
+}
+
+//ValidateRange returns true if data matches the chunk checksums stored by AddChunked for the bytes starting at offset.
+//offset must fall on a chunk boundary, and data must end on a chunk boundary or at the end of the original data.
+//Like Validate, a nil hash validates against every chunked sum with the given name.
+func (c *KnownSums) ValidateRange(name string, hash *crypto.Hash, offset int64, data []byte) (bool, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Checker == nil {
+		return false, ErrNilChecker
+	}
+	if len(data) == 0 {
+		return false, fmt.Errorf("data cannot be empty")
+	}
+	all := chunkedSums(withKeyID(withNameAndHash(c.knownSums, name, hash), c.KeyID))
+	sums := availableSums(all)
+	if len(all) == 0 {
+		return false, fmt.Errorf(`error validating range of known sum %s: %w`, name, ErrNotFound)
+	}
+	err := c.checkPolicy(all, sums)
+	if err != nil {
+		return false, fmt.Errorf(`error validating range of known sum %s: %w`, name, err)
+	}
+	if len(sums) == 0 {
+		return false, fmt.Errorf(`error validating range of known sum %s: %w`, name, ErrUnavailableHash)
+	}
+	sums = c.trustedSums(sums)
+	if len(sums) == 0 {
+		return false, fmt.Errorf(`error validating range of known sum %s: %w`, name, ErrWeakHash)
+	}
+	for _, sum := range sums {
+		ok, err := c.validateRange(sum, offset, data)
+		if err != nil {
+			return false, fmt.Errorf(`error validating range of known sum %s: %w`, name, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *KnownSums) validateRange(sum *knownSum, offset int64, data []byte) (bool, error) {
+	chunkSize := int64(sum.ChunkSize)
+	if offset < 0 || offset%chunkSize != 0 {
+		return false, fmt.Errorf("offset %d is not a multiple of the chunk size %d", offset, chunkSize)
+	}
+	end := offset + int64(len(data))
+	if end > sum.Size {
+		return false, nil
+	}
+	if end%chunkSize != 0 && end != sum.Size {
+		return false, fmt.Errorf("range must end on a chunk boundary or at the end of the data")
+	}
+	for idx := offset / chunkSize; len(data) > 0; idx++ {
+		n := chunkSize
+		if n > int64(len(data)) {
+			n = int64(len(data))
+		}
+		ok, err := c.Checker.ValidateReader(sum.Hash, sum.Chunks[idx], bytes.NewReader(data[:n]))
+		if err != nil || !ok {
+			return false, err
+		}
+		data = data[n:]
+	}
+	return true, nil
+}
+
+//chunkedSums returns the sums that have a chunk checksum for every chunk of their data
+func chunkedSums(sums []*knownSum) []*knownSum {
+	result := make([]*knownSum, 0, len(sums))
+	for _, sum := range sums {
+		if sum.ChunkSize > 0 && int64(len(sum.Chunks)) == (sum.Size+int64(sum.ChunkSize)-1)/int64(sum.ChunkSize) {
+			result = append(result, sum)
+		}
+	}
+	return result
+}
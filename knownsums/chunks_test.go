@@ -0,0 +1,125 @@
+package knownsums
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Sum(data string) []byte {
+	sum := sha256.Sum256([]byte(data))
+	return sum[:]
+}
+
+func TestKnownSums_AddChunked(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		err := knownSums.AddChunked("sumname", crypto.SHA256, 4, iotest.OneByteReader(strings.NewReader("foobarbazqux!")))
+		require.NoError(t, err)
+		want := []*knownSum{
+			{
+				Name:      "sumname",
+				Hash:      crypto.SHA256,
+				Checksum:  sha256Sum("foobarbazqux!"),
+				Size:      13,
+				ChunkSize: 4,
+				Chunks: [][]byte{
+					sha256Sum("foob"),
+					sha256Sum("arba"),
+					sha256Sum("zqux"),
+					sha256Sum("!"),
+				},
+			},
+		}
+		assert.Equal(t, want, knownSums.knownSums)
+	})
+
+	t.Run("invalid chunk size", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		err := knownSums.AddChunked("sumname", crypto.SHA256, 0, strings.NewReader("foo"))
+		assert.EqualError(t, err, "chunk size must be positive")
+		assert.Empty(t, knownSums.knownSums)
+	})
+
+	t.Run("unregistered hash", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		err := knownSums.AddChunked("sumname", 999, 4, strings.NewReader("foo"))
+		assert.EqualError(t, err, "hash is not available")
+		assert.Empty(t, knownSums.knownSums)
+	})
+}
+
+func TestKnownSums_ValidateRange(t *testing.T) {
+	data := "foobarbazqux!"
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	require.NoError(t, knownSums.AddChunked("sumname", crypto.SHA256, 4, strings.NewReader(data)))
+	require.NoError(t, knownSums.AddChunked("sumname", crypto.SHA512, 8, strings.NewReader(data)))
+	require.NoError(t, knownSums.AddPrecalculatedSum("unchunked", crypto.SHA256, sha256Sum(data)))
+	sha256Hash := crypto.SHA256
+
+	for _, td := range []struct {
+		name   string
+		hash   *crypto.Hash
+		offset int64
+		data   string
+		want   bool
+		err    string
+	}{
+		{name: "whole data", offset: 0, data: data, want: true},
+		{name: "first chunk", hash: &sha256Hash, offset: 0, data: "foob", want: true},
+		{name: "middle chunks", hash: &sha256Hash, offset: 4, data: "arbazqux", want: true},
+		{name: "last partial chunk", hash: &sha256Hash, offset: 12, data: "!", want: true},
+		{name: "aligned for both hashes", offset: 8, data: "zqux!", want: true},
+		{name: "wrong data", hash: &sha256Hash, offset: 4, data: "arbx", want: false},
+		{name: "past the end", hash: &sha256Hash, offset: 12, data: "!!", want: false},
+		{name: "unaligned offset", hash: &sha256Hash, offset: 2, data: "ob", err: "error validating range of known sum sumname: offset 2 is not a multiple of the chunk size 4"},
+		{name: "unaligned end", hash: &sha256Hash, offset: 4, data: "ar", err: "error validating range of known sum sumname: range must end on a chunk boundary or at the end of the data"},
+		{name: "unaligned for one hash", offset: 4, data: "arba", err: "error validating range of known sum sumname: offset 4 is not a multiple of the chunk size 8"},
+		{name: "empty data", offset: 0, data: "", err: "data cannot be empty"},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			got, err := knownSums.ValidateRange("sumname", td.hash, td.offset, []byte(td.data))
+			if td.err != "" {
+				assert.EqualError(t, err, td.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, td.want, got)
+		})
+	}
+
+	t.Run("no chunks", func(t *testing.T) {
+		got, err := knownSums.ValidateRange("unchunked", nil, 0, []byte(data))
+		assert.EqualError(t, err, "error validating range of known sum unchunked: no known sum for name and hash")
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.False(t, got)
+	})
+
+	t.Run("after json round trip", func(t *testing.T) {
+		b, err := json.Marshal(knownSums)
+		require.NoError(t, err)
+		loaded := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		require.NoError(t, json.Unmarshal(b, loaded))
+		got, err := loaded.ValidateRange("sumname", nil, 8, []byte("zqux!"))
+		assert.NoError(t, err)
+		assert.True(t, got)
+	})
+}
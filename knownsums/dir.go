@@ -0,0 +1,233 @@
+package knownsums
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type fileSum struct {
+	Name     string
+	Checksum []byte
+}
+
+//DirDiff lists the files that differ between a directory and the files recorded when it was added with AddDir.
+//Names are slash-separated paths relative to the directory.
+type DirDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+//Empty returns true when there are no differences
+func (d *DirDiff) Empty() bool {
+	return d == nil || len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+//AddDir adds a single checksum for every regular file in dir. Other file types such as symlinks are ignored.
+//
+//The checksum is calculated like Go's dirhash: each file's path relative to dir and checksum are written as a
+//"<hex>  <slash-separated path>\n" line in path order and the checksum of those lines is the directory's checksum.
+//Each file's checksum is also stored so that ValidateDir can report which files changed.
+func (c *KnownSums) AddDir(name string, hash crypto.Hash, dir string) error {
+	if c.Checker == nil {
+		return ErrNilChecker
+	}
+	err := c.checkHash(hash)
+	if err != nil {
+		return err
+	}
+	files, err := c.dirFileSums(dir, []crypto.Hash{hash})
+	if err != nil {
+		return err
+	}
+	sum, err := c.dirSum(hash, files[hash])
+	if err != nil {
+		return err
+	}
+	dirFiles := files[hash]
+	if dirFiles == nil {
+		dirFiles = []fileSum{}
+	}
+	return c.addKnownSum(&knownSum{
+		Name:     name,
+		Hash:     hash,
+		Checksum: sum,
+		Files:    dirFiles,
+	})
+}
+
+//ValidateDir returns true if dir's checksum matches the known sum added with AddDir.
+//When it doesn't match, the returned DirDiff lists the files that were added, removed or changed.
+//Like Validate, a nil hash validates against every known sum added with AddDir with the given name. Other known sums
+//with the name are ignored.
+func (c *KnownSums) ValidateDir(name string, hash *crypto.Hash, dir string) (bool, *DirDiff, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Checker == nil {
+		return false, nil, ErrNilChecker
+	}
+	all := dirSums(withKeyID(withNameAndHash(c.knownSums, name, hash), c.KeyID))
+	sums := availableSums(all)
+	if len(all) == 0 {
+		return false, nil, fmt.Errorf(`error validating known sum %s: %w`, name, ErrNotFound)
+	}
+	err := c.checkPolicy(all, sums)
+	if err != nil {
+		return false, nil, fmt.Errorf(`error validating known sum %s: %w`, name, err)
+	}
+	if len(sums) == 0 {
+		return false, nil, fmt.Errorf(`error validating known sum %s: %w`, name, ErrUnavailableHash)
+	}
+	sums = c.trustedSums(sums)
+	if len(sums) == 0 {
+		return false, nil, fmt.Errorf(`error validating known sum %s: %w`, name, ErrWeakHash)
+	}
+	hashes := make([]crypto.Hash, len(sums))
+	for i, sum := range sums {
+		hashes[i] = sum.Hash
+	}
+	files, err := c.dirFileSums(dir, hashes)
+	if err != nil {
+		return false, nil, fmt.Errorf(`error validating known sum %s: %w`, name, err)
+	}
+	ok := true
+	diff := &DirDiff{}
+	for _, sum := range sums {
+		got, err := c.dirSum(sum.Hash, files[sum.Hash])
+		if err != nil {
+			return false, nil, fmt.Errorf(`error validating known sum %s: %w`, name, err)
+		}
+		if c.Checker.Equal(sum.Checksum, got) {
+			continue
+		}
+		ok = false
+		diff.merge(c.diffFiles(sum.Files, files[sum.Hash]))
+	}
+	if ok {
+		return true, nil, nil
+	}
+	return false, diff, nil
+}
+
+//dirSums returns the sums that were added with AddDir
+func dirSums(sums []*knownSum) []*knownSum {
+	result := make([]*knownSum, 0, len(sums))
+	for _, sum := range sums {
+		if sum.isDir() {
+			result = append(result, sum)
+		}
+	}
+	return result
+}
+
+//dirFileSums returns the checksums of every regular file in dir for each of hashes sorted by path
+func (c *KnownSums) dirFileSums(dir string, hashes []crypto.Hash) (map[crypto.Hash][]fileSum, error) {
+	result := make(map[crypto.Hash][]fileSum, len(hashes))
+	err := filepath.Walk(dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.ContainsAny(rel, "\n") {
+			return fmt.Errorf("file names containing newlines are not supported: %q", rel)
+		}
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		sums, err := c.Checker.MultiChecksum(hashes, file)
+		if err != nil {
+			return fmt.Errorf("error calculating sum for %s: %w", rel, err)
+		}
+		for hsh, sum := range sums {
+			result[hsh] = append(result[hsh], fileSum{
+				Name:     rel,
+				Checksum: sum,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, files := range result {
+		sortFileSums(files)
+	}
+	return result, nil
+}
+
+//dirSum calculates the aggregate checksum of files, which must be sorted by name
+func (c *KnownSums) dirSum(hash crypto.Hash, files []fileSum) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, file := range files {
+		_, err := fmt.Fprintf(&buf, "%x  %s\n", file.Checksum, file.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Checker.ChecksumReader(hash, &buf)
+}
+
+func (c *KnownSums) diffFiles(want, got []fileSum) *DirDiff {
+	diff := &DirDiff{}
+	wantSums := make(map[string][]byte, len(want))
+	for _, file := range want {
+		wantSums[file.Name] = file.Checksum
+	}
+	gotNames := make(map[string]bool, len(got))
+	for _, file := range got {
+		gotNames[file.Name] = true
+		wantSum, ok := wantSums[file.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, file.Name)
+		case !c.Checker.Equal(wantSum, file.Checksum):
+			diff.Changed = append(diff.Changed, file.Name)
+		}
+	}
+	for _, file := range want {
+		if !gotNames[file.Name] {
+			diff.Removed = append(diff.Removed, file.Name)
+		}
+	}
+	return diff
+}
+
+//merge adds the names from other that aren't already in d
+func (d *DirDiff) merge(other *DirDiff) {
+	d.Added = mergeNames(d.Added, other.Added)
+	d.Removed = mergeNames(d.Removed, other.Removed)
+	d.Changed = mergeNames(d.Changed, other.Changed)
+}
+
+func mergeNames(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var result []string
+	for _, name := range append(append([]string{}, a...), b...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func sortFileSums(files []fileSum) {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+}
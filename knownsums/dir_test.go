@@ -0,0 +1,179 @@
+package knownsums
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tmpDir(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	for name, content := range files {
+		writeTmpFile(t, dir, name, content)
+	}
+	return dir, func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func writeTmpFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	filename := filepath.Join(dir, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0750))
+	require.NoError(t, ioutil.WriteFile(filename, []byte(content), 0640))
+}
+
+func TestKnownSums_AddDir(t *testing.T) {
+	dir, teardown := tmpDir(t, map[string]string{
+		"foo":         "foo",
+		"sub/empty":   "",
+		"sub/sub/foo": "foo",
+	})
+	defer teardown()
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	err := knownSums.AddDir("dir", crypto.SHA256, dir)
+	require.NoError(t, err)
+
+	// sha256sum foo sub/empty sub/sub/foo | sha256sum
+	wantSum := "24bcc07af0fe7905e67e277c9a165ab580cbd4a22739886b754c451369361bae"
+	want := []*knownSum{
+		{
+			Name:     "dir",
+			Hash:     crypto.SHA256,
+			Checksum: mustHexDecode(t, wantSum),
+			Files: []fileSum{
+				{Name: "foo", Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"])},
+				{Name: "sub/empty", Checksum: mustHexDecode(t, knownHexSums["sha256"][""])},
+				{Name: "sub/sub/foo", Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"])},
+			},
+		},
+	}
+	assert.Equal(t, want, knownSums.knownSums)
+
+	t.Run("json round trip", func(t *testing.T) {
+		b, err := json.Marshal(knownSums)
+		require.NoError(t, err)
+		var got KnownSums
+		require.NoError(t, json.Unmarshal(b, &got))
+		assert.Equal(t, want, got.knownSums)
+	})
+}
+
+func TestKnownSums_AddDir_empty(t *testing.T) {
+	dir, teardown := tmpDir(t, nil)
+	defer teardown()
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	require.NoError(t, knownSums.AddDir("dir", crypto.SHA256, dir))
+
+	b, err := json.Marshal(knownSums)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"files":[]`)
+	got := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	require.NoError(t, json.Unmarshal(b, got))
+	assert.Equal(t, knownSums.knownSums, got.knownSums)
+
+	ok, diff, err := got.ValidateDir("dir", nil, dir)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, diff)
+}
+
+func TestKnownSums_ValidateDir(t *testing.T) {
+	files := map[string]string{
+		"foo":         "foo",
+		"sub/empty":   "",
+		"sub/sub/foo": "foo",
+	}
+	setup := func(t *testing.T) (*KnownSums, string, func()) {
+		t.Helper()
+		dir, teardown := tmpDir(t, files)
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		require.NoError(t, knownSums.AddDir("dir", crypto.SHA256, dir))
+		require.NoError(t, knownSums.AddDir("dir", crypto.MD5, dir))
+		return knownSums, dir, teardown
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		knownSums, dir, teardown := setup(t)
+		defer teardown()
+		ok, diff, err := knownSums.ValidateDir("dir", nil, dir)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Nil(t, diff)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		knownSums, dir, teardown := setup(t)
+		defer teardown()
+		writeTmpFile(t, dir, "sub/empty", "not empty")
+		writeTmpFile(t, dir, "sub/new", "new")
+		require.NoError(t, os.Remove(filepath.Join(dir, "foo")))
+		ok, diff, err := knownSums.ValidateDir("dir", nil, dir)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, &DirDiff{
+			Added:   []string{"sub/new"},
+			Removed: []string{"foo"},
+			Changed: []string{"sub/empty"},
+		}, diff)
+		assert.False(t, diff.Empty())
+	})
+
+	t.Run("renamed", func(t *testing.T) {
+		knownSums, dir, teardown := setup(t)
+		defer teardown()
+		require.NoError(t, os.Rename(filepath.Join(dir, "foo"), filepath.Join(dir, "bar")))
+		hash := crypto.SHA256
+		ok, diff, err := knownSums.ValidateDir("dir", &hash, dir)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, &DirDiff{
+			Added:   []string{"bar"},
+			Removed: []string{"foo"},
+		}, diff)
+	})
+
+	t.Run("ignores file sums", func(t *testing.T) {
+		knownSums, dir, teardown := setup(t)
+		defer teardown()
+		require.NoError(t, knownSums.Add("dir", crypto.SHA512, []byte("not a dir")))
+		ok, diff, err := knownSums.ValidateDir("dir", nil, dir)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Nil(t, diff)
+
+		hash := crypto.SHA512
+		ok, diff, err = knownSums.ValidateDir("dir", &hash, dir)
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.False(t, ok)
+		assert.Nil(t, diff)
+	})
+
+	t.Run("unknown name", func(t *testing.T) {
+		knownSums, dir, teardown := setup(t)
+		defer teardown()
+		ok, diff, err := knownSums.ValidateDir("bogus", nil, dir)
+		assert.EqualError(t, err, "error validating known sum bogus: no known sum for name and hash")
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.False(t, ok)
+		assert.Nil(t, diff)
+	})
+}
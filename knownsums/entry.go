@@ -0,0 +1,88 @@
+package knownsums
+
+import (
+	"crypto"
+	"encoding/json"
+)
+
+//Entry is a copy of a checksum stored in KnownSums. Changing it doesn't change KnownSums.
+type Entry struct {
+	Name     string
+	Hash     crypto.Hash
+	Checksum []byte
+	//KeyID identifies the key of a keyed (HMAC) checksum. It is empty for unkeyed checksums.
+	KeyID string
+	//HashName is only set when Hash is 0 because the checksum was loaded with a hash name that hashnames doesn't know.
+	HashName string
+}
+
+func (k *knownSum) entry() Entry {
+	return Entry{
+		Name:     k.Name,
+		Hash:     k.Hash,
+		Checksum: append([]byte(nil), k.Checksum...),
+		KeyID:    k.KeyID,
+		HashName: k.HashName,
+	}
+}
+
+//MarshalJSON uses the same format as the entries in KnownSums' JSON
+func (e Entry) MarshalJSON() ([]byte, error) {
+	sum := &knownSum{
+		Name:     e.Name,
+		Hash:     e.Hash,
+		Checksum: e.Checksum,
+		KeyID:    e.KeyID,
+		HashName: e.HashName,
+	}
+	return json.Marshal(sum.jsonKnownSum())
+}
+
+//Len returns the number of checksums in KnownSums
+func (c *KnownSums) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.knownSums)
+}
+
+//Entries returns every checksum in KnownSums in the order they were added
+func (c *KnownSums) Entries() []Entry {
+	return c.entries(func(sum *knownSum) bool {
+		return true
+	})
+}
+
+//Lookup returns the checksums for name in the order they were added
+func (c *KnownSums) Lookup(name string) []Entry {
+	return c.entries(func(sum *knownSum) bool {
+		return sum.Name == name
+	})
+}
+
+//Each calls fn for each checksum in the order they were added until fn returns false.
+//When hash is not nil, only checksums using hash are included.
+//fn must not modify KnownSums.
+func (c *KnownSums) Each(hash *crypto.Hash, fn func(Entry) bool) {
+	c.RLock()
+	defer c.RUnlock()
+	for _, sum := range c.knownSums {
+		if hash != nil && sum.Hash != *hash {
+			continue
+		}
+		if !fn(sum.entry()) {
+			return
+		}
+	}
+}
+
+func (c *KnownSums) entries(filter func(*knownSum) bool) []Entry {
+	c.RLock()
+	defer c.RUnlock()
+	result := make([]Entry, 0, len(c.knownSums))
+	for _, sum := range c.knownSums {
+		if filter(sum) {
+			result = append(result, sum.entry())
+		}
+	}
+	return result
+}
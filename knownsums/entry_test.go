@@ -0,0 +1,117 @@
+package knownsums
+
+import (
+	"crypto"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func entriesKnownSums() *KnownSums {
+	return &KnownSums{
+		knownSums: []*knownSum{
+			{Name: "foo", Hash: crypto.MD5, Checksum: []byte("foo")},
+			{Name: "bar", Hash: crypto.SHA256, Checksum: []byte("bar")},
+			{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("baz"), KeyID: "abc"},
+		},
+	}
+}
+
+func TestKnownSums_Len(t *testing.T) {
+	assert.Equal(t, 3, entriesKnownSums().Len())
+	assert.Equal(t, 0, (&KnownSums{}).Len())
+}
+
+func TestKnownSums_Entries(t *testing.T) {
+	knownSums := entriesKnownSums()
+	got := knownSums.Entries()
+	want := []Entry{
+		{Name: "foo", Hash: crypto.MD5, Checksum: []byte("foo")},
+		{Name: "bar", Hash: crypto.SHA256, Checksum: []byte("bar")},
+		{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("baz"), KeyID: "abc"},
+	}
+	assert.Equal(t, want, got)
+
+	got[0].Checksum[0] = 'x'
+	assert.Equal(t, []byte("foo"), knownSums.knownSums[0].Checksum)
+}
+
+func TestKnownSums_Lookup(t *testing.T) {
+	knownSums := entriesKnownSums()
+	want := []Entry{
+		{Name: "foo", Hash: crypto.MD5, Checksum: []byte("foo")},
+		{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("baz"), KeyID: "abc"},
+	}
+	assert.Equal(t, want, knownSums.Lookup("foo"))
+	assert.Empty(t, knownSums.Lookup("bogus"))
+}
+
+func TestKnownSums_Each(t *testing.T) {
+	knownSums := entriesKnownSums()
+
+	t.Run("all", func(t *testing.T) {
+		var got []string
+		knownSums.Each(nil, func(entry Entry) bool {
+			got = append(got, entry.Name)
+			return true
+		})
+		assert.Equal(t, []string{"foo", "bar", "foo"}, got)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		var got []string
+		hash := crypto.SHA256
+		knownSums.Each(&hash, func(entry Entry) bool {
+			got = append(got, string(entry.Checksum))
+			return true
+		})
+		assert.Equal(t, []string{"bar", "baz"}, got)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		count := 0
+		knownSums.Each(nil, func(entry Entry) bool {
+			count++
+			return false
+		})
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestEntry_MarshalJSON(t *testing.T) {
+	got, err := json.Marshal(entriesKnownSums().Entries())
+	require.NoError(t, err)
+	want := `
+[
+  {"name": "foo", "hash": "md5", "checksum": "666f6f"},
+  {"name": "bar", "hash": "sha256", "checksum": "626172"},
+  {"name": "foo", "hash": "sha256", "key_id": "abc", "checksum": "62617a"}
+]
+`
+	assert.JSONEq(t, want, string(got))
+}
+
+func TestEntry_unknownHashName(t *testing.T) {
+	j := `[{"name": "foo", "hash": "sha256_custom", "checksum": "666f6f"}]`
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	require.NoError(t, json.Unmarshal([]byte(j), knownSums))
+	entries := knownSums.Entries()
+	assert.Equal(t, []Entry{
+		{Name: "foo", Checksum: []byte("foo"), HashName: "sha256_custom"},
+	}, entries)
+	got, err := json.Marshal(entries)
+	require.NoError(t, err)
+	assert.JSONEq(t, j, string(got))
+
+	result, err := knownSums.ValidateDetailed("foo", nil, strings.NewReader("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []EntryResult{
+		{HashName: "sha256_custom", Status: StatusUnavailableHash, Expected: []byte("foo")},
+	}, result.Skipped())
+}
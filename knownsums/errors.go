@@ -0,0 +1,25 @@
+package knownsums
+
+import (
+	"errors"
+
+	"github.com/WillAbides/checksum/sumchecker"
+)
+
+var (
+	//ErrNilChecker is returned when KnownSums doesn't have a Checker
+	ErrNilChecker = errors.New("checker cannot be nil")
+	//ErrUnavailableHash is returned when a checksum is added with a hash that isn't linked into the binary
+	ErrUnavailableHash = errors.New("hash is not available")
+	//ErrDuplicate is returned when adding a checksum for a name and hash that already has one
+	ErrDuplicate = errors.New("cannot add duplicate name and hash")
+	//ErrNotFound is returned when there is no known sum for a name and hash
+	ErrNotFound = errors.New("no known sum for name and hash")
+	//ErrWeakHash is the same as sumchecker.ErrWeakHash. It is returned when a hash is weaker than MinStrength.
+	ErrWeakHash = sumchecker.ErrWeakHash
+	//ErrMismatch is the same as sumchecker.ErrMismatch. errors.Is(err, ErrMismatch) is true for a *MismatchError.
+	ErrMismatch = sumchecker.ErrMismatch
+)
+
+//MismatchError is the same as sumchecker.MismatchError so mismatches from either package can be handled together
+type MismatchError = sumchecker.MismatchError
@@ -0,0 +1,54 @@
+//Package all links an implementation of every hash hashnames knows into the binary so it is available, and registers
+//the non-crypto.Hash algorithms crc32c, xxh64 and blake3 with hashnames.Register.
+//Import it for its side effects:
+//
+//	import _ "github.com/WillAbides/checksum/knownsums/hashnames/all"
+//
+//md5sha1 is the only hash left out. Neither the standard library nor golang.org/x/crypto registers an implementation
+//of it because it is only used internally by TLS 1.0 and 1.1.
+package all
+
+import (
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"hash"
+	"hash/crc32"
+
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/cespare/xxhash/v2"
+	_ "golang.org/x/crypto/blake2b"
+	_ "golang.org/x/crypto/blake2s"
+	_ "golang.org/x/crypto/md4"
+	_ "golang.org/x/crypto/ripemd160"
+	_ "golang.org/x/crypto/sha3"
+	"lukechampine.com/blake3"
+)
+
+//The values hashnames.Register returned for the registered hashes
+var (
+	//CRC32C is CRC-32 with the Castagnoli polynomial. It detects accidental corruption but isn't cryptographic.
+	CRC32C crypto.Hash
+	//XXH64 is the 64 bit xxHash. It detects accidental corruption but isn't cryptographic.
+	XXH64 crypto.Hash
+	//BLAKE3 is BLAKE3 with a 256 bit digest
+	BLAKE3 crypto.Hash
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func init() {
+	CRC32C = hashnames.MustRegister("crc32c", func() hash.Hash {
+		return crc32.New(castagnoli)
+	})
+	XXH64 = hashnames.MustRegister("xxh64", func() hash.Hash {
+		return xxhash.New()
+	})
+	BLAKE3 = hashnames.MustRegister("blake3", func() hash.Hash {
+		return blake3.New(32, nil)
+	})
+	sumchecker.SetHashStrength(BLAKE3, sumchecker.StrengthStrong)
+}
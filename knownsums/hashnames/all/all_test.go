@@ -0,0 +1,144 @@
+package all_test
+
+import (
+	"crypto"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/WillAbides/checksum/knownsums"
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+	"github.com/WillAbides/checksum/knownsums/hashnames/all"
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"lukechampine.com/blake3"
+)
+
+var names = []string{
+	"md4",
+	"md5",
+	"sha1",
+	"sha224",
+	"sha256",
+	"sha384",
+	"sha512",
+	"ripemd160",
+	"sha3_224",
+	"sha3_256",
+	"sha3_384",
+	"sha3_512",
+	"sha512_224",
+	"sha512_256",
+	"blake2s_256",
+	"blake2b_256",
+	"blake2b_384",
+	"blake2b_512",
+	"crc32c",
+	"xxh64",
+	"blake3",
+}
+
+func TestAvailable(t *testing.T) {
+	for _, name := range names {
+		assert.True(t, hashnames.Available(hashnames.LookupHash(name)), name)
+	}
+	assert.False(t, hashnames.Available(hashnames.LookupHash("md5sha1")))
+}
+
+func TestKnownSumsJSON(t *testing.T) {
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			hash := hashnames.LookupHash(name)
+			knownSums := &knownsums.KnownSums{
+				Checker: sumchecker.New(nil),
+			}
+			require.NoError(t, knownSums.Add("foo", hash, []byte("foo")))
+			b, err := json.Marshal(knownSums)
+			require.NoError(t, err)
+			assert.Contains(t, string(b), `"hash":"`+name+`"`)
+
+			loaded := &knownsums.KnownSums{
+				Checker: sumchecker.New(nil),
+			}
+			require.NoError(t, json.Unmarshal(b, loaded))
+			assert.Equal(t, knownSums.Entries(), loaded.Entries())
+			ok, err := loaded.ValidateReader("foo", &hash, strings.NewReader("foo"))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = loaded.ValidateReader("foo", &hash, strings.NewReader("bar"))
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+//cryptoRunner is a HashRunner that doesn't implement HashFuncRunner
+type cryptoRunner struct{}
+
+func (r *cryptoRunner) WithHash(hsh crypto.Hash, fn func(hash.Hash) error) error {
+	return fn(hsh.New())
+}
+
+func TestRegistered(t *testing.T) {
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.Checksum([]byte("foo"), crc32.MakeTable(crc32.Castagnoli)))
+	xxh := make([]byte, 8)
+	binary.BigEndian.PutUint64(xxh, xxhash.Sum64String("foo"))
+	b3 := blake3.Sum256([]byte("foo"))
+	for _, td := range []struct {
+		hash     crypto.Hash
+		name     string
+		want     []byte
+		strength sumchecker.Strength
+	}{
+		{hash: all.CRC32C, name: "crc32c", want: crc, strength: sumchecker.StrengthBroken},
+		{hash: all.XXH64, name: "xxh64", want: xxh, strength: sumchecker.StrengthBroken},
+		{hash: all.BLAKE3, name: "blake3", want: b3[:], strength: sumchecker.StrengthStrong},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			assert.Equal(t, td.name, hashnames.HashName(td.hash))
+			assert.Equal(t, td.hash, hashnames.LookupHash(td.name))
+			assert.Contains(t, hashnames.AvailableHashes(), td.hash)
+			assert.Equal(t, crypto.Hash(0), hashnames.TreeHash(td.hash, 1024))
+			assert.Equal(t, td.strength, sumchecker.HashStrength(td.hash))
+			for _, runner := range []sumchecker.HashRunner{nil, sumchecker.NewPoolRunner()} {
+				got, err := sumchecker.New(runner).ChecksumReader(td.hash, strings.NewReader("foo"))
+				require.NoError(t, err)
+				assert.Equal(t, td.want, got)
+			}
+			_, err := sumchecker.New(sumchecker.NewHMACRunner([]byte("key"))).ChecksumReader(td.hash, strings.NewReader("foo"))
+			assert.NoError(t, err)
+			_, err = sumchecker.New(&cryptoRunner{}).ChecksumReader(td.hash, strings.NewReader("foo"))
+			assert.Equal(t, sumchecker.ErrUnregisteredHash, err)
+			_, err = hashnames.Register(td.name, func() hash.Hash {
+				return crc32.NewIEEE()
+			})
+			assert.EqualError(t, err, "duplicate names are not allowed")
+		})
+	}
+}
+
+func TestRegister_concurrentLookups(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			_, err := hashnames.Register(fmt.Sprintf("concurrent-%d", i), func() hash.Hash {
+				return crc32.NewIEEE()
+			})
+			assert.NoError(t, err)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, "sha256", hashnames.HashName(crypto.SHA256))
+		assert.Equal(t, all.BLAKE3, hashnames.LookupHash("blake3"))
+	}
+	<-done
+	assert.Equal(t, "concurrent-9", hashnames.HashName(hashnames.LookupHash("concurrent-9")))
+}
@@ -3,6 +3,8 @@ package hashnames
 import (
 	"crypto"
 	"fmt"
+	"hash"
+	"math/bits"
 	"regexp"
 	"sort"
 	"strconv"
@@ -11,6 +13,27 @@ import (
 
 const invalid = "invalid"
 
+//treeHashFlag marks a crypto.Hash value as a tree hash. Bits 8-15 hold log2 of the chunk size and bits 0-7 hold the
+//base crypto.Hash.
+const treeHashFlag crypto.Hash = 1 << 16
+
+//registeredHashFlag marks a crypto.Hash value as a hash added with Register. The lower bits hold the order it was
+//registered in.
+const registeredHashFlag crypto.Hash = 1 << 24
+
+//DefaultTreeChunkSize is the chunk size of the tree hashes listed by AvailableHashes.
+const DefaultTreeChunkSize = 1 << 20
+
+//MinTreeChunkSize and MaxTreeChunkSize limit the chunk sizes of tree hashes. Smaller chunks make a tree hash slow and
+//chunks are held in memory while they are hashed, so larger chunks could exhaust it.
+const (
+	MinTreeChunkSize = 1 << minTreeChunkShift
+	MaxTreeChunkSize = 1 << maxTreeChunkShift
+
+	minTreeChunkShift = 4
+	maxTreeChunkShift = 26
+)
+
 var knownNames []string
 var knownHashes []crypto.Hash
 var reverseKnownHashNames map[string]crypto.Hash
@@ -60,6 +83,53 @@ func resetValues() {
 
 var mux sync.RWMutex
 
+var registeredHashes = map[crypto.Hash]func() hash.Hash{}
+
+//Register makes a hash that isn't a crypto.Hash, like a non-cryptographic checksum, available with the given name.
+//It returns the crypto.Hash value that identifies it everywhere a crypto.Hash is accepted. The value depends on the
+//order hashes are registered in, so store the name instead of the value.
+//Registered hashes can't be the base of a tree hash.
+func Register(name string, fn func() hash.Hash) (crypto.Hash, error) {
+	mux.Lock()
+	defer mux.Unlock()
+	if name == "" || fn == nil {
+		return 0, fmt.Errorf("name and fn are required")
+	}
+	_, duplicate := reverseKnownHashNames[name]
+	if duplicate || name == invalid || reTreeNameLookup.MatchString(name) || reNameLookup.MatchString(name) {
+		return 0, fmt.Errorf("duplicate names are not allowed")
+	}
+	registered := registeredHashFlag | crypto.Hash(len(registeredHashes)+1)
+	registeredHashes[registered] = fn
+	knownHashNames[registered] = name
+	resetValues()
+	return registered, nil
+}
+
+//MustRegister is like Register but panics on error. It is intended to be used in init funcs.
+func MustRegister(name string, fn func() hash.Hash) crypto.Hash {
+	registered, err := Register(name, fn)
+	if err != nil {
+		panic(fmt.Sprintf("error registering hash %s: %v", name, err))
+	}
+	return registered
+}
+
+//HashFunc returns a func that creates a new hash.Hash for hsh. It works for crypto.Hashes that are linked into the
+//binary and hashes added with Register. ok is false for tree hashes and hashes that aren't available.
+func HashFunc(hsh crypto.Hash) (fn func() hash.Hash, ok bool) {
+	if hsh&^0xffffff == registeredHashFlag {
+		mux.RLock()
+		fn = registeredHashes[hsh]
+		mux.RUnlock()
+		return fn, fn != nil
+	}
+	if hsh > 0xff || !hsh.Available() {
+		return nil, false
+	}
+	return hsh.New, true
+}
+
 func UpdateHashName(hash crypto.Hash, name string) error {
 	mux.Lock()
 	defer mux.Unlock()
@@ -72,7 +142,42 @@ func UpdateHashName(hash crypto.Hash, name string) error {
 	return nil
 }
 
-//AvailableHashes lists all available crypto.Hashes
+//TreeHash returns a value that identifies a Merkle tree hash that splits input into chunkSize chunks and hashes them
+//with base. chunkSize must be a power of two from MinTreeChunkSize to MaxTreeChunkSize. It returns 0 when base or
+//chunkSize are invalid.
+func TreeHash(base crypto.Hash, chunkSize int) crypto.Hash {
+	if base == 0 || base > 0xff || chunkSize < MinTreeChunkSize || chunkSize > MaxTreeChunkSize ||
+		chunkSize&(chunkSize-1) != 0 {
+		return 0
+	}
+	return treeHashFlag | crypto.Hash(bits.TrailingZeros(uint(chunkSize)))<<8 | base
+}
+
+//TreeHashParams returns the base hash and chunk size of a hash created by TreeHash.
+//ok is false when hash isn't a tree hash or its chunk size is outside MinTreeChunkSize and MaxTreeChunkSize.
+func TreeHashParams(hash crypto.Hash) (base crypto.Hash, chunkSize int, ok bool) {
+	if hash&^0xffff != treeHashFlag {
+		return 0, 0, false
+	}
+	shift := uint(hash >> 8 & 0xff)
+	if shift < minTreeChunkShift || shift > maxTreeChunkShift {
+		return 0, 0, false
+	}
+	return hash & 0xff, 1 << shift, true
+}
+
+//Available reports whether hash can be calculated in this binary. It is true for linked crypto.Hashes and hashes
+//added with Register. Tree hashes are available when their base hash is available.
+func Available(hash crypto.Hash) bool {
+	if base, _, ok := TreeHashParams(hash); ok {
+		hash = base
+	}
+	_, ok := HashFunc(hash)
+	return ok
+}
+
+//AvailableHashes lists all available crypto.Hashes, then the hashes added with Register, followed by a tree hash with
+//DefaultTreeChunkSize for each of the crypto.Hashes
 func AvailableHashes() []crypto.Hash {
 	result := make([]crypto.Hash, 0, 256)
 	for i := crypto.Hash(0); i < 256; i++ {
@@ -80,6 +185,15 @@ func AvailableHashes() []crypto.Hash {
 			result = append(result, i)
 		}
 	}
+	cryptoHashes := len(result)
+	mux.RLock()
+	for i := 1; i <= len(registeredHashes); i++ {
+		result = append(result, registeredHashFlag|crypto.Hash(i))
+	}
+	mux.RUnlock()
+	for _, hash := range result[:cryptoHashes] {
+		result = append(result, TreeHash(hash, DefaultTreeChunkSize))
+	}
 	return result
 }
 
@@ -92,27 +206,74 @@ func AvailableHashNames() []string {
 	return result
 }
 
-//HashName returns either the name mapped in KnownHashNames of "unknown(%d)"
+//HashName returns either the name mapped in KnownHashNames of "unknown(%d)".
+//Tree hashes are named "tree-<base name>-<chunk size>" like "tree-sha256-1MiB".
 func HashName(hash crypto.Hash) string {
+	mux.RLock()
 	name, ok := knownHashNames[hash]
+	mux.RUnlock()
 	if ok {
 		return name
 	}
+	if base, chunkSize, isTree := TreeHashParams(hash); isTree {
+		return fmt.Sprintf("tree-%s-%s", HashName(base), formatChunkSize(chunkSize))
+	}
 	if hash == 0 {
 		return invalid
 	}
 	return fmt.Sprintf("unknown(%d)", hash)
 }
 
+var chunkSizeUnits = []struct {
+	suffix string
+	size   int
+}{
+	{suffix: "GiB", size: 1 << 30},
+	{suffix: "MiB", size: 1 << 20},
+	{suffix: "KiB", size: 1 << 10},
+	{suffix: "B", size: 1},
+}
+
+func formatChunkSize(chunkSize int) string {
+	for _, unit := range chunkSizeUnits {
+		if chunkSize%unit.size == 0 {
+			return fmt.Sprintf("%d%s", chunkSize/unit.size, unit.suffix)
+		}
+	}
+	return strconv.Itoa(chunkSize)
+}
+
+func parseChunkSize(s string) int {
+	for _, unit := range chunkSizeUnits {
+		if len(s) <= len(unit.suffix) || s[len(s)-len(unit.suffix):] != unit.suffix {
+			continue
+		}
+		n, err := strconv.ParseUint(s[:len(s)-len(unit.suffix)], 10, 32)
+		if err != nil {
+			return 0
+		}
+		return int(n) * unit.size
+	}
+	return 0
+}
+
 var reNameLookup = regexp.MustCompile(`unknown\((\d+)\)`)
 
+var reTreeNameLookup = regexp.MustCompile(`^tree-(.+)-(\d+(?:GiB|MiB|KiB|B))$`)
+
 func LookupHash(name string) crypto.Hash {
-	if result, ok := reverseKnownHashNames[name]; ok {
+	mux.RLock()
+	result, ok := reverseKnownHashNames[name]
+	mux.RUnlock()
+	if ok {
 		return result
 	}
 	if name == invalid {
 		return 0
 	}
+	if matches := reTreeNameLookup.FindStringSubmatch(name); len(matches) > 0 {
+		return TreeHash(LookupHash(matches[1]), parseChunkSize(matches[2]))
+	}
 	matches := reNameLookup.FindStringSubmatch(name)
 	if len(matches) > 0 {
 		n, err := strconv.ParseUint(matches[1], 10, 32)
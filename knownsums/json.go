@@ -8,8 +8,19 @@ import (
 )
 
 type jsonKnownSum struct {
+	Name      string   `json:"name"`
+	HashName  string   `json:"hash"`
+	KeyID     string   `json:"key_id,omitempty"`
+	Checksum  string   `json:"checksum"`
+	Size      int64    `json:"size,omitempty"`
+	ChunkSize int      `json:"chunk_size,omitempty"`
+	Chunks    []string `json:"chunks,omitempty"`
+	//Files is a pointer so that an empty directory is saved as "files": []
+	Files *[]*jsonFileSum `json:"files,omitempty"`
+}
+
+type jsonFileSum struct {
 	Name     string `json:"name"`
-	HashName string `json:"hash"`
 	Checksum string `json:"checksum"`
 }
 
@@ -18,18 +29,78 @@ func (j *jsonKnownSum) knownSum() (*knownSum, error) {
 	if err != nil {
 		return nil, err
 	}
+	var chunks [][]byte
+	if len(j.Chunks) > 0 {
+		chunks = make([][]byte, len(j.Chunks))
+		for i, chunk := range j.Chunks {
+			chunks[i], err = hex.DecodeString(chunk)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	var files []fileSum
+	if j.Files != nil {
+		files = make([]fileSum, len(*j.Files))
+		for i, file := range *j.Files {
+			files[i].Name = file.Name
+			files[i].Checksum, err = hex.DecodeString(file.Checksum)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sortFileSums(files)
+	}
+	hash := hashnames.LookupHash(j.HashName)
+	var unknownHashName string
+	if hash == 0 {
+		unknownHashName = j.HashName
+	}
 	return &knownSum{
-		Name:     j.Name,
-		Hash:     hashnames.LookupHash(j.HashName),
-		Checksum: sum,
+		Name:      j.Name,
+		Hash:      hash,
+		HashName:  unknownHashName,
+		Checksum:  sum,
+		KeyID:     j.KeyID,
+		Size:      j.Size,
+		ChunkSize: j.ChunkSize,
+		Chunks:    chunks,
+		Files:     files,
 	}, nil
 }
 
 func (k *knownSum) jsonKnownSum() *jsonKnownSum {
+	var chunks []string
+	if len(k.Chunks) > 0 {
+		chunks = make([]string, len(k.Chunks))
+		for i, chunk := range k.Chunks {
+			chunks[i] = hex.EncodeToString(chunk)
+		}
+	}
+	var files *[]*jsonFileSum
+	if k.isDir() {
+		fileSums := make([]*jsonFileSum, len(k.Files))
+		for i, file := range k.Files {
+			fileSums[i] = &jsonFileSum{
+				Name:     file.Name,
+				Checksum: hex.EncodeToString(file.Checksum),
+			}
+		}
+		files = &fileSums
+	}
+	hashName := hashnames.HashName(k.Hash)
+	if k.Hash == 0 && k.HashName != "" {
+		hashName = k.HashName
+	}
 	return &jsonKnownSum{
-		Name:     k.Name,
-		HashName: hashnames.HashName(k.Hash),
-		Checksum: hex.EncodeToString(k.Checksum),
+		Name:      k.Name,
+		HashName:  hashName,
+		KeyID:     k.KeyID,
+		Checksum:  hex.EncodeToString(k.Checksum),
+		Size:      k.Size,
+		ChunkSize: k.ChunkSize,
+		Chunks:    chunks,
+		Files:     files,
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/WillAbides/checksum/knownsums/hashnames"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -76,7 +77,7 @@ func TestKnownSums_UnmarshalJSON(t *testing.T) {
 	got := KnownSums{}
 	err := json.Unmarshal([]byte(j), &got)
 	assert.NoError(t, err)
-	assert.Equal(t, want, got)
+	assert.Equal(t, &want, &got)
 }
 
 func TestKnownSum_UnmarshalJSON(t *testing.T) {
@@ -100,6 +101,76 @@ func TestKnownSum_UnmarshalJSON(t *testing.T) {
 		assert.Equal(t, want, got)
 	})
 
+	t.Run("tree hash", func(t *testing.T) {
+		j := `
+{
+  "name": "foo",
+  "hash": "tree-sha256-1MiB",
+  "checksum": "62617a"
+}
+`
+		want := knownSum{
+			Name:     "foo",
+			Hash:     hashnames.TreeHash(crypto.SHA256, 1<<20),
+			Checksum: []byte("baz"),
+		}
+
+		var got knownSum
+		err := json.Unmarshal([]byte(j), &got)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		b, err := json.Marshal(&got)
+		assert.NoError(t, err)
+		assert.JSONEq(t, j, string(b))
+	})
+
+	t.Run("unknown hash name", func(t *testing.T) {
+		j := `
+{
+  "name": "foo",
+  "hash": "somehash",
+  "checksum": "62617a"
+}
+`
+		want := knownSum{
+			Name:     "foo",
+			HashName: "somehash",
+			Checksum: []byte("baz"),
+		}
+
+		var got knownSum
+		err := json.Unmarshal([]byte(j), &got)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		b, err := json.Marshal(&got)
+		assert.NoError(t, err)
+		assert.JSONEq(t, j, string(b))
+	})
+
+	t.Run("keyed", func(t *testing.T) {
+		j := `
+{
+  "name": "foo",
+  "hash": "sha256",
+  "key_id": "005725b48609c45e",
+  "checksum": "62617a"
+}
+`
+		want := knownSum{
+			Name:     "foo",
+			Hash:     crypto.SHA256,
+			Checksum: []byte("baz"),
+			KeyID:    "005725b48609c45e",
+		}
+
+		var got knownSum
+		err := json.Unmarshal([]byte(j), &got)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
 	t.Run("slice", func(t *testing.T) {
 		j := `
 [
@@ -156,6 +227,55 @@ func TestKnownSum_MarshalJSON(t *testing.T) {
 
 	})
 
+	t.Run("chunked", func(t *testing.T) {
+		ks := &knownSum{
+			Name:      "foo",
+			Hash:      crypto.MD5,
+			Checksum:  []byte("baz"),
+			Size:      5,
+			ChunkSize: 4,
+			Chunks:    [][]byte{[]byte("bar"), []byte("qux")},
+		}
+		want := `
+{
+  "name": "foo",
+  "hash": "md5",
+  "checksum": "62617a",
+  "size": 5,
+  "chunk_size": 4,
+  "chunks": ["626172", "717578"]
+}
+`
+		got, err := json.MarshalIndent(ks, "", "  ")
+		assert.NoError(t, err)
+		assert.JSONEq(t, want, string(got))
+
+		var roundTrip knownSum
+		err = json.Unmarshal(got, &roundTrip)
+		assert.NoError(t, err)
+		assert.Equal(t, ks, &roundTrip)
+	})
+
+	t.Run("keyed", func(t *testing.T) {
+		ks := &knownSum{
+			Name:     "foo",
+			Hash:     crypto.SHA256,
+			Checksum: []byte("baz"),
+			KeyID:    "005725b48609c45e",
+		}
+		want := `
+{
+  "name": "foo",
+  "hash": "sha256",
+  "key_id": "005725b48609c45e",
+  "checksum": "62617a"
+}
+`
+		got, err := json.MarshalIndent(ks, "", "  ")
+		assert.NoError(t, err)
+		assert.JSONEq(t, want, string(got))
+	})
+
 	t.Run("slice", func(t *testing.T) {
 		ks := []*knownSum{
 			{
@@ -1,39 +1,78 @@
 package knownsums
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"fmt"
+	"io"
 	"sync"
+
+	"github.com/WillAbides/checksum/internal/ctxio"
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+	"github.com/WillAbides/checksum/sumchecker"
 )
 
 type Checker interface {
-	Checksum(hasher crypto.Hash, data []byte) ([]byte, error)
-	ValidateChecksum(hasher crypto.Hash, wantSum []byte, data []byte) (bool, error)
+	ChecksumReader(hasher crypto.Hash, r io.Reader) ([]byte, error)
+	ValidateReader(hasher crypto.Hash, wantSum []byte, r io.Reader) (bool, error)
+	MultiChecksum(hashes []crypto.Hash, r io.Reader) (map[crypto.Hash][]byte, error)
+	Equal(wantSum, sum []byte) bool
 }
 
 type knownSum struct {
 	Hash     crypto.Hash
 	Name     string
 	Checksum []byte
+	KeyID    string
+	//HashName is only set when a sum is loaded with a hash name that hashnames doesn't know, like the name of a hash
+	//that was added with hashnames.Register in another binary. It lets the sum be saved again without losing the name.
+	HashName string
+	//Size, ChunkSize and Chunks are only set for sums added with AddChunked
+	Size      int64
+	ChunkSize int
+	Chunks    [][]byte
+	//Files is only set for sums added with AddDir. It is empty but not nil for an empty directory.
+	Files []fileSum
+}
+
+//isDir returns true for sums added with AddDir
+func (k *knownSum) isDir() bool {
+	return k.Files != nil
 }
 
 //KnownSums contains a list of checksums that can be validated with the Validate func
 type KnownSums struct {
 	sync.RWMutex
-	Checker   Checker
-	knownSums []*knownSum
+	Checker Checker
+	//KeyID identifies the key Checker uses for keyed (HMAC) checksums. It is empty for unkeyed checksums.
+	//Added sums are recorded with KeyID, and only sums with a matching KeyID are validated.
+	KeyID string
+	//UnavailablePolicy decides how validation treats known sums whose hash isn't available. The default is
+	//UnavailableSkip.
+	UnavailablePolicy UnavailablePolicy
+	//MinStrength is the weakest hash that sums can be added with. Known sums with weaker hashes aren't trusted when
+	//validating. The default allows every hash.
+	MinStrength sumchecker.Strength
+	knownSums   []*knownSum
 }
 
 //Add adds a checksum that can be validated by KnownSums.
 //It uses KnownSums' SumChecker to calculate data's checksum.
 func (c *KnownSums) Add(name string, hash crypto.Hash, data []byte) error {
+	return c.AddReader(name, hash, bytes.NewReader(data))
+}
+
+//AddReader is like Add but calculates the checksum of everything read from r.
+func (c *KnownSums) AddReader(name string, hash crypto.Hash, r io.Reader) error {
 	if c.Checker == nil {
-		return fmt.Errorf("checker cannot be nil")
+		return ErrNilChecker
 	}
-	if !hash.Available() {
-		return fmt.Errorf("hash is not available")
+	err := c.checkHash(hash)
+	if err != nil {
+		return err
 	}
-	sum, err := c.Checker.Checksum(hash, data)
+	sum, err := c.Checker.ChecksumReader(hash, r)
 	if err != nil {
 		return fmt.Errorf("error calculating sum: %w", err)
 	}
@@ -43,18 +82,106 @@ func (c *KnownSums) Add(name string, hash crypto.Hash, data []byte) error {
 //AddPrecalculatedSum adds a sum that has already been calculated.
 //This is primarily intended to be used for serialization
 func (c *KnownSums) AddPrecalculatedSum(name string, hash crypto.Hash, sum []byte) error {
+	return c.addKnownSum(&knownSum{
+		Name:     name,
+		Hash:     hash,
+		Checksum: sum,
+	})
+}
+
+//addKnownSum adds sum with KnownSums' KeyID
+func (c *KnownSums) addKnownSum(sum *knownSum) error {
+	return c.addKnownSums(sum)
+}
+
+//addKnownSums adds all of sums with KnownSums' KeyID. Nothing is added if any of them is a duplicate.
+func (c *KnownSums) addKnownSums(sums ...*knownSum) error {
 	c.Lock()
 	defer c.Unlock()
-	existing := withNameAndHash(c.knownSums, name, &hash)
-	if len(existing) != 0 {
-		return fmt.Errorf("cannot add duplicate name and hash")
+	combined := append(make([]*knownSum, 0, len(c.knownSums)+len(sums)), c.knownSums...)
+	for _, sum := range sums {
+		err := sumchecker.CheckStrength(sum.Hash, c.MinStrength)
+		if err != nil {
+			return err
+		}
+		existing := withKeyID(withNameAndHash(combined, sum.Name, &sum.Hash), c.KeyID)
+		if len(existing) != 0 {
+			return ErrDuplicate
+		}
+		sum.KeyID = c.KeyID
+		combined = append(combined, sum)
 	}
-	c.knownSums = append(c.knownSums, &knownSum{
+	c.knownSums = combined
+	return nil
+}
+
+//Set stores sum as the checksum for name and hash, replacing any existing checksum in a single atomic operation.
+//It returns the checksum that was replaced or nil if there wasn't one. Like Add, it refuses hashes weaker than
+//MinStrength.
+func (c *KnownSums) Set(name string, hash crypto.Hash, sum []byte) ([]byte, error) {
+	return c.setKnownSum(&knownSum{
 		Name:     name,
 		Hash:     hash,
 		Checksum: sum,
 	})
-	return nil
+}
+
+//Upsert is like Add but replaces any existing checksum for name and hash instead of returning an error.
+//It returns the checksum that was replaced or nil if there wasn't one.
+func (c *KnownSums) Upsert(name string, hash crypto.Hash, data []byte) ([]byte, error) {
+	return c.UpsertReader(name, hash, bytes.NewReader(data))
+}
+
+//UpsertReader is like Upsert but calculates the checksum of everything read from r.
+func (c *KnownSums) UpsertReader(name string, hash crypto.Hash, r io.Reader) ([]byte, error) {
+	if c.Checker == nil {
+		return nil, ErrNilChecker
+	}
+	err := c.checkHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := c.Checker.ChecksumReader(hash, r)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating sum: %w", err)
+	}
+	return c.Set(name, hash, sum)
+}
+
+//setKnownSum replaces the existing sum with the same name, hash and KnownSums' KeyID in place or adds sum if there
+//isn't one. It returns the replaced checksum.
+func (c *KnownSums) setKnownSum(sum *knownSum) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	err := sumchecker.CheckStrength(sum.Hash, c.MinStrength)
+	if err != nil {
+		return nil, err
+	}
+	sum.KeyID = c.KeyID
+	for i, existing := range c.knownSums {
+		if existing.KeyID == c.KeyID && matchNameAndHash(sum.Name, &sum.Hash, existing) {
+			c.knownSums[i] = sum
+			return existing.Checksum, nil
+		}
+	}
+	c.knownSums = append(c.knownSums, sum)
+	return nil, nil
+}
+
+//Names returns the name of every checksum in KnownSums in the order they were added without duplicates
+func (c *KnownSums) Names() []string {
+	c.RLock()
+	defer c.RUnlock()
+	seen := make(map[string]bool, len(c.knownSums))
+	names := make([]string, 0, len(c.knownSums))
+	for _, sum := range c.knownSums {
+		if seen[sum.Name] {
+			continue
+		}
+		seen[sum.Name] = true
+		names = append(names, sum.Name)
+	}
+	return names
 }
 
 //Remove removes a checksum from KnownSums
@@ -71,34 +198,118 @@ func (c *KnownSums) Remove(name string, hash *crypto.Hash) {
 	c.knownSums = newSums
 }
 
+//Select returns a new KnownSums with the same Checker and settings that only contains the known sums with the given
+//name and hash. Like Validate, a nil hash selects every known sum with the given name.
+//Changes to either KnownSums don't affect the other, so the result can be validated without holding KnownSums' lock.
+func (c *KnownSums) Select(name string, hash *crypto.Hash) *KnownSums {
+	c.RLock()
+	defer c.RUnlock()
+	return &KnownSums{
+		Checker:           c.Checker,
+		KeyID:             c.KeyID,
+		UnavailablePolicy: c.UnavailablePolicy,
+		MinStrength:       c.MinStrength,
+		knownSums:         withNameAndHash(c.knownSums, name, hash),
+	}
+}
+
 //Validate returns true if data's checksum matches the sum stored in KnownSums.
 //Looks for the known sum with the given name and hashName and uses SumChecker to validate that the sums match.
 //If hashName is empty, it will return true if all known sums with the given name return true.
 func (c *KnownSums) Validate(name string, hash *crypto.Hash, data []byte) (bool, error) {
+	return c.ValidateReader(name, hash, bytes.NewReader(data))
+}
+
+//ValidateReader is like Validate but validates everything read from r.
+//When more than one known sum needs to be checked, all of them are calculated in a single pass over r.
+func (c *KnownSums) ValidateReader(name string, hash *crypto.Hash, r io.Reader) (bool, error) {
+	result, err := c.ValidateDetailed(name, hash, r)
+	if err != nil {
+		return false, err
+	}
+	return result.OK(), nil
+}
+
+//ValidateDetailed is like ValidateReader but returns the result of checking each known sum with the given name and
+//hash, including sums that were skipped because their hash isn't available or is weaker than MinStrength.
+//When there are no known sums for name and hash, the result has a single entry with StatusNoEntry.
+func (c *KnownSums) ValidateDetailed(name string, hash *crypto.Hash, r io.Reader) (*ValidationResult, error) {
 	c.RLock()
 	defer c.RUnlock()
 	if c.Checker == nil {
-		return false, fmt.Errorf("checker cannot be nil")
+		return nil, ErrNilChecker
 	}
-	sums := withNameAndHash(c.knownSums, name, hash)
-	var err error
-	var ok bool
-	for _, sum := range sums {
-		if !sum.Hash.Available() {
-			continue
+	result := &ValidationResult{
+		Name:   name,
+		Policy: c.UnavailablePolicy,
+	}
+	sums := withKeyID(withNameAndHash(c.knownSums, name, hash), c.KeyID)
+	if len(sums) == 0 {
+		noEntry := EntryResult{
+			Status: StatusNoEntry,
+		}
+		if hash != nil {
+			noEntry.Hash = *hash
 		}
-		ok, err = c.Checker.ValidateChecksum(sum.Hash, sum.Checksum, data)
+		result.Entries = append(result.Entries, noEntry)
+		return result, nil
+	}
+	checked := c.trustedSums(availableSums(sums))
+	hashes := make([]crypto.Hash, len(checked))
+	for i, sum := range checked {
+		hashes[i] = sum.Hash
+	}
+	var got map[crypto.Hash][]byte
+	if len(hashes) > 0 {
+		var err error
+		got, err = c.Checker.MultiChecksum(hashes, r)
 		if err != nil {
-			err = fmt.Errorf(`error validating known sum %s: %w`, name, err)
-			break
+			return nil, fmt.Errorf(`error validating known sum %s: %w`, name, err)
 		}
-		if !ok {
-			break
+	}
+	for _, sum := range sums {
+		entry := EntryResult{
+			Hash:     sum.Hash,
+			HashName: sum.HashName,
+			Expected: append([]byte(nil), sum.Checksum...),
+		}
+		actual, ok := got[sum.Hash]
+		switch {
+		case !hashnames.Available(sum.Hash):
+			entry.Status = StatusUnavailableHash
+		case !ok:
+			entry.Status = StatusWeakHash
+		case c.Checker.Equal(sum.Checksum, actual):
+			entry.Status = StatusMatched
+			entry.Actual = actual
+		default:
+			entry.Status = StatusMismatched
+			entry.Actual = actual
 		}
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+//ValidateContext is like ValidateReader but stops reading and returns ctx.Err() when ctx is done.
+func (c *KnownSums) ValidateContext(ctx context.Context, name string, hash *crypto.Hash, r io.Reader) (bool, error) {
+	ok, err := c.ValidateReader(name, hash, ctxio.NewReader(ctx, r))
+	if ctx.Err() != nil {
+		return false, ctx.Err()
 	}
 	return ok, err
 }
 
+func availableSums(sums []*knownSum) []*knownSum {
+	result := make([]*knownSum, 0, len(sums))
+	for _, sum := range sums {
+		if hashnames.Available(sum.Hash) {
+			result = append(result, sum)
+		}
+	}
+	return result
+}
+
 //returns true if sum.Name == name and hash is either nil or matches sum.HashName
 func matchNameAndHash(name string, hash *crypto.Hash, sum *knownSum) bool {
 	if sum == nil {
@@ -119,3 +330,13 @@ func withNameAndHash(sums []*knownSum, name string, hash *crypto.Hash) []*knownS
 	}
 	return result
 }
+
+func withKeyID(sums []*knownSum, keyID string) []*knownSum {
+	result := make([]*knownSum, 0, len(sums))
+	for _, sum := range sums {
+		if sum.KeyID == keyID {
+			result = append(result, sum)
+		}
+	}
+	return result
+}
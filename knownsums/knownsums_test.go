@@ -1,14 +1,19 @@
 package knownsums
 
 import (
+	"context"
 	"crypto"
 	_ "crypto/md5"
 	_ "crypto/sha1"
 	_ "crypto/sha256"
 	_ "crypto/sha512"
 	"encoding/hex"
+	"errors"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/WillAbides/checksum/knownsums/hashnames"
 	"github.com/WillAbides/checksum/sumchecker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -68,6 +73,7 @@ func TestKnownSums_Add(t *testing.T) {
 		name := "sumname"
 		err := knownSums.Add(name, 999, data)
 		assert.EqualError(t, err, "hash is not available")
+		assert.Equal(t, ErrUnavailableHash, err)
 		assert.Empty(t, knownSums.knownSums)
 	})
 
@@ -77,6 +83,7 @@ func TestKnownSums_Add(t *testing.T) {
 		name := "sumname"
 		err := knownSums.Add(name, crypto.MD5, data)
 		assert.EqualError(t, err, "checker cannot be nil")
+		assert.Equal(t, ErrNilChecker, err)
 		assert.Empty(t, knownSums.knownSums)
 	})
 }
@@ -235,6 +242,7 @@ func TestKnownSums_AddPrecalculatedSum(t *testing.T) {
 		}
 		err := knownSums.AddPrecalculatedSum(name, hash, []byte("bar"))
 		assert.EqualError(t, err, "cannot add duplicate name and hash")
+		assert.Equal(t, ErrDuplicate, err)
 		assert.Equal(t, []*knownSum{{
 			Name:     name,
 			Hash:     hash,
@@ -306,3 +314,376 @@ func TestKnownSums_Remove(t *testing.T) {
 		assert.ElementsMatch(t, want, knownSums.knownSums)
 	})
 }
+
+func TestKnownSums_AddReader(t *testing.T) {
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	err := knownSums.AddReader("sumname", crypto.SHA256, strings.NewReader("foo"))
+	assert.NoError(t, err)
+	want := []*knownSum{
+		{
+			Hash:     crypto.SHA256,
+			Name:     "sumname",
+			Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+		},
+	}
+	assert.Equal(t, want, knownSums.knownSums)
+}
+
+func TestKnownSums_ValidateReader(t *testing.T) {
+	name := "sumname"
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+		knownSums: []*knownSum{
+			{
+				Name:     name,
+				Hash:     crypto.MD5,
+				Checksum: mustHexDecode(t, knownHexSums["md5"]["foo"]),
+			},
+			{
+				Name:     name,
+				Hash:     crypto.SHA256,
+				Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+			},
+		},
+	}
+
+	t.Run("single hash", func(t *testing.T) {
+		hash := crypto.SHA256
+		got, err := knownSums.ValidateReader(name, &hash, iotest.OneByteReader(strings.NewReader("foo")))
+		assert.NoError(t, err)
+		assert.True(t, got)
+	})
+
+	t.Run("multiple hashes in one pass", func(t *testing.T) {
+		got, err := knownSums.ValidateReader(name, nil, iotest.OneByteReader(strings.NewReader("foo")))
+		assert.NoError(t, err)
+		assert.True(t, got)
+	})
+
+	t.Run("multiple hashes invalid", func(t *testing.T) {
+		got, err := knownSums.ValidateReader(name, nil, strings.NewReader("bar"))
+		assert.NoError(t, err)
+		assert.False(t, got)
+	})
+}
+
+func TestKnownSums_Validate_constantTime(t *testing.T) {
+	name := "sumname"
+	sums := func() []*knownSum {
+		return []*knownSum{
+			{
+				Name:     name,
+				Hash:     crypto.MD5,
+				Checksum: mustHexDecode(t, knownHexSums["md5"]["foo"]),
+			},
+			{
+				Name:     name,
+				Hash:     crypto.SHA256,
+				Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+			},
+		}
+	}
+	defaultSums := &KnownSums{
+		Checker:   sumchecker.New(nil),
+		knownSums: sums(),
+	}
+	constantTimeSums := &KnownSums{
+		Checker:   sumchecker.New(nil, sumchecker.WithConstantTimeCompare()),
+		knownSums: sums(),
+	}
+	for _, data := range []string{"foo", "bar", ""} {
+		want, err := defaultSums.Validate(name, nil, []byte(data))
+		require.NoError(t, err)
+		got, err := constantTimeSums.Validate(name, nil, []byte(data))
+		require.NoError(t, err)
+		assert.Equal(t, data == "foo", want)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestKnownSums_keyed(t *testing.T) {
+	key := []byte("Jefe")
+	data := []byte("what do ya want for nothing?")
+	name := "sumname"
+	hash := crypto.SHA256
+	keyedSums := &KnownSums{
+		Checker: sumchecker.New(sumchecker.NewHMACRunner(key)),
+		KeyID:   sumchecker.KeyID(key),
+	}
+	err := keyedSums.Add(name, hash, data)
+	require.NoError(t, err)
+	assert.Equal(t, []*knownSum{
+		{
+			Name:     name,
+			Hash:     hash,
+			Checksum: mustHexDecode(t, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"),
+			KeyID:    "005725b48609c45e",
+		},
+	}, keyedSums.knownSums)
+
+	got, err := keyedSums.Validate(name, &hash, data)
+	assert.NoError(t, err)
+	assert.True(t, got)
+
+	t.Run("unkeyed sum with the same name and hash", func(t *testing.T) {
+		err := keyedSums.AddPrecalculatedSum(name, hash, []byte("foo"))
+		assert.EqualError(t, err, "cannot add duplicate name and hash")
+
+		unkeyedSums := &KnownSums{
+			Checker:   sumchecker.New(nil),
+			knownSums: keyedSums.knownSums,
+		}
+		err = unkeyedSums.Add(name, hash, data)
+		assert.NoError(t, err)
+		got, err := unkeyedSums.Validate(name, &hash, data)
+		assert.NoError(t, err)
+		assert.True(t, got)
+	})
+
+	t.Run("different key", func(t *testing.T) {
+		otherKey := []byte("bogus")
+		otherSums := &KnownSums{
+			Checker:   sumchecker.New(sumchecker.NewHMACRunner(otherKey)),
+			KeyID:     sumchecker.KeyID(otherKey),
+			knownSums: keyedSums.knownSums,
+		}
+		got, err := otherSums.Validate(name, &hash, data)
+		assert.NoError(t, err)
+		assert.False(t, got)
+	})
+}
+
+func TestKnownSums_ValidateContext(t *testing.T) {
+	name := "sumname"
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+		knownSums: []*knownSum{
+			{
+				Name:     name,
+				Hash:     crypto.SHA256,
+				Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+			},
+		},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		got, err := knownSums.ValidateContext(context.Background(), name, nil, strings.NewReader("foo"))
+		assert.NoError(t, err)
+		assert.True(t, got)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		got, err := knownSums.ValidateContext(ctx, name, nil, strings.NewReader("foo"))
+		assert.Equal(t, context.Canceled, err)
+		assert.False(t, got)
+	})
+}
+
+func TestKnownSums_treeHash(t *testing.T) {
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	data := []byte(strings.Repeat("foo", 1000))
+	hash := hashnames.TreeHash(crypto.SHA256, 1024)
+	err := knownSums.Add("sumname", hash, data)
+	require.NoError(t, err)
+	got, err := knownSums.Validate("sumname", nil, data)
+	assert.NoError(t, err)
+	assert.True(t, got)
+	got, err = knownSums.Validate("sumname", nil, data[1:])
+	assert.NoError(t, err)
+	assert.False(t, got)
+}
+
+func TestKnownSums_Names(t *testing.T) {
+	knownSums := &KnownSums{
+		knownSums: []*knownSum{
+			{Name: "foo", Hash: crypto.MD5},
+			{Name: "bar", Hash: crypto.MD5},
+			{Name: "foo", Hash: crypto.SHA256},
+		},
+	}
+	assert.Equal(t, []string{"foo", "bar"}, knownSums.Names())
+	assert.Empty(t, (&KnownSums{}).Names())
+}
+
+func TestKnownSums_Set(t *testing.T) {
+	knownSums := &KnownSums{
+		knownSums: []*knownSum{
+			{Name: "foo", Hash: crypto.MD5, Checksum: []byte("foo")},
+			{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("bar")},
+			{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("keyed"), KeyID: "abc"},
+		},
+	}
+
+	t.Run("replace", func(t *testing.T) {
+		got, err := knownSums.Set("foo", crypto.SHA256, []byte("baz"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("bar"), got)
+		assert.Equal(t, []*knownSum{
+			{Name: "foo", Hash: crypto.MD5, Checksum: []byte("foo")},
+			{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("baz")},
+			{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("keyed"), KeyID: "abc"},
+		}, knownSums.knownSums)
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		got, err := knownSums.Set("bar", crypto.SHA256, []byte("qux"))
+		require.NoError(t, err)
+		assert.Nil(t, got)
+		assert.Equal(t, &knownSum{Name: "bar", Hash: crypto.SHA256, Checksum: []byte("qux")}, knownSums.knownSums[3])
+	})
+}
+
+func TestKnownSums_Upsert(t *testing.T) {
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+	}
+	got, err := knownSums.Upsert("sumname", crypto.MD5, []byte(""))
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = knownSums.Upsert("sumname", crypto.MD5, []byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, mustHexDecode(t, knownHexSums["md5"][""]), got)
+	assert.Equal(t, []*knownSum{
+		{
+			Name:     "sumname",
+			Hash:     crypto.MD5,
+			Checksum: mustHexDecode(t, knownHexSums["md5"]["foo"]),
+		},
+	}, knownSums.knownSums)
+
+	_, err = knownSums.Upsert("sumname", 999, []byte("foo"))
+	assert.EqualError(t, err, "hash is not available")
+}
+
+func TestKnownSums_ValidateDetailed(t *testing.T) {
+	name := "sumname"
+	knownSums := &KnownSums{
+		Checker: sumchecker.New(nil),
+		knownSums: []*knownSum{
+			{
+				Name:     name,
+				Hash:     crypto.MD5,
+				Checksum: mustHexDecode(t, knownHexSums["md5"]["foo"]),
+			},
+			{
+				Name:     name,
+				Hash:     crypto.SHA256,
+				Checksum: []byte("deadbeef"),
+			},
+			{
+				Name:     name,
+				Hash:     999,
+				Checksum: []byte("deadbeef"),
+			},
+		},
+	}
+
+	t.Run("all entries", func(t *testing.T) {
+		got, err := knownSums.ValidateDetailed(name, nil, strings.NewReader("foo"))
+		require.NoError(t, err)
+		want := &ValidationResult{
+			Name: name,
+			Entries: []EntryResult{
+				{
+					Hash:     crypto.MD5,
+					Status:   StatusMatched,
+					Expected: mustHexDecode(t, knownHexSums["md5"]["foo"]),
+					Actual:   mustHexDecode(t, knownHexSums["md5"]["foo"]),
+				},
+				{
+					Hash:     crypto.SHA256,
+					Status:   StatusMismatched,
+					Expected: []byte("deadbeef"),
+					Actual:   mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+				},
+				{
+					Hash:     999,
+					Status:   StatusUnavailableHash,
+					Expected: []byte("deadbeef"),
+				},
+			},
+		}
+		assert.Equal(t, want, got)
+		assert.False(t, got.OK())
+		assert.Equal(t, &MismatchError{
+			Name:     name,
+			Hash:     crypto.SHA256,
+			Expected: []byte("deadbeef"),
+			Actual:   mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+		}, got.Err())
+		assert.True(t, errors.Is(got.Err(), ErrMismatch))
+	})
+
+	t.Run("matched and unavailable", func(t *testing.T) {
+		hash := crypto.MD5
+		got, err := knownSums.ValidateDetailed(name, &hash, strings.NewReader("foo"))
+		require.NoError(t, err)
+		assert.True(t, got.OK())
+		assert.Len(t, got.Entries, 1)
+		assert.NoError(t, got.Err())
+	})
+
+	t.Run("only unavailable", func(t *testing.T) {
+		hash := crypto.Hash(999)
+		got, err := knownSums.ValidateDetailed(name, &hash, strings.NewReader("foo"))
+		require.NoError(t, err)
+		assert.False(t, got.OK())
+		assert.Equal(t, StatusUnavailableHash, got.Entries[0].Status)
+		assert.Equal(t, ErrUnavailableHash, got.Err())
+	})
+
+	t.Run("no entry", func(t *testing.T) {
+		hash := crypto.SHA512
+		got, err := knownSums.ValidateDetailed(name, &hash, strings.NewReader("foo"))
+		require.NoError(t, err)
+		assert.Equal(t, &ValidationResult{
+			Name: name,
+			Entries: []EntryResult{
+				{Hash: crypto.SHA512, Status: StatusNoEntry},
+			},
+		}, got)
+		assert.False(t, got.OK())
+		assert.Equal(t, ErrNotFound, got.Err())
+	})
+}
+
+func TestStatus_String(t *testing.T) {
+	assert.Equal(t, "matched", StatusMatched.String())
+	assert.Equal(t, "no entry", StatusNoEntry.String())
+	assert.Equal(t, "Status(0)", Status(0).String())
+}
+
+func TestKnownSums_Select(t *testing.T) {
+	knownSums := &KnownSums{
+		Checker:     sumchecker.New(nil),
+		KeyID:       "abc",
+		MinStrength: sumchecker.StrengthWeak,
+		knownSums: []*knownSum{
+			{Name: "foo", Hash: crypto.SHA1, Checksum: []byte("foo")},
+			{Name: "bar", Hash: crypto.SHA256, Checksum: []byte("bar")},
+			{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("baz")},
+		},
+	}
+	hash := crypto.SHA256
+	got := knownSums.Select("foo", &hash)
+	assert.Equal(t, &KnownSums{
+		Checker:     knownSums.Checker,
+		KeyID:       "abc",
+		MinStrength: sumchecker.StrengthWeak,
+		knownSums: []*knownSum{
+			{Name: "foo", Hash: crypto.SHA256, Checksum: []byte("baz")},
+		},
+	}, got)
+	assert.Len(t, knownSums.Select("foo", nil).Entries(), 2)
+
+	_, err := got.Set("foo", crypto.SHA256, []byte("qux"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("baz"), knownSums.knownSums[2].Checksum)
+}
@@ -0,0 +1,194 @@
+package knownsums
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+)
+
+//ImportCoreutils adds the checksums from a GNU coreutils style manifest like the ones written by sha256sum and
+//md5sum. Each line is "<hex>  <name>" or "<hex> *<name>" for files checksummed in binary mode. Coreutils manifests
+//don't name their algorithm, so every checksum is added with hash.
+//Nothing is added when any line can't be imported.
+func (c *KnownSums) ImportCoreutils(r io.Reader, hash crypto.Hash) error {
+	var sums []*knownSum
+	err := scanManifest(r, func(line string) error {
+		line, escaped := trimEscapePrefix(line)
+		idx := strings.IndexByte(line, ' ')
+		if idx < 1 || idx+2 > len(line) || (line[idx+1] != ' ' && line[idx+1] != '*') {
+			return fmt.Errorf("improperly formatted checksum line")
+		}
+		sum, err := hex.DecodeString(line[:idx])
+		if err != nil {
+			return err
+		}
+		name := line[idx+2:]
+		if escaped {
+			name = unescapeManifestName(name)
+		}
+		sums = append(sums, &knownSum{
+			Name:     name,
+			Hash:     hash,
+			Checksum: sum,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.addKnownSums(sums...)
+}
+
+var reBSDLine = regexp.MustCompile(`^(\S+) \((.*)\) = ([0-9a-fA-F]+)$`)
+
+//ImportBSD adds the checksums from a BSD style manifest like the ones written by "sha256sum --tag" or the BSD
+//sha256 command. Each line is "<ALGORITHM> (<name>) = <hex>". Nothing is added when any line can't be imported.
+func (c *KnownSums) ImportBSD(r io.Reader) error {
+	var sums []*knownSum
+	err := scanManifest(r, func(line string) error {
+		line, escaped := trimEscapePrefix(line)
+		matches := reBSDLine.FindStringSubmatch(line)
+		if len(matches) == 0 {
+			return fmt.Errorf("improperly formatted checksum line")
+		}
+		hash := lookupBSDHash(matches[1])
+		if hash == 0 {
+			return fmt.Errorf("unknown algorithm %q", matches[1])
+		}
+		sum, err := hex.DecodeString(matches[3])
+		if err != nil {
+			return err
+		}
+		name := matches[2]
+		if escaped {
+			name = unescapeManifestName(name)
+		}
+		sums = append(sums, &knownSum{
+			Name:     name,
+			Hash:     hash,
+			Checksum: sum,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.addKnownSums(sums...)
+}
+
+//ExportCoreutils writes the checksums that use hash as a GNU coreutils style manifest that can be checked with
+//tools like "sha256sum -c". Checksums loaded with a hash name that hashnames doesn't know and directory checksums
+//added with AddDir are never written because those tools can't check them.
+func (c *KnownSums) ExportCoreutils(w io.Writer, hash crypto.Hash) error {
+	c.RLock()
+	defer c.RUnlock()
+	for _, sum := range withKeyID(c.knownSums, c.KeyID) {
+		if sum.Hash != hash || sum.Hash == 0 || sum.isDir() {
+			continue
+		}
+		prefix, name := escapeManifestName(sum.Name)
+		_, err := fmt.Fprintf(w, "%s%x  %s\n", prefix, sum.Checksum, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ExportBSD writes checksums as a BSD style manifest. When hash is nil, checksums for every algorithm are written,
+//including checksums loaded with a hash name that hashnames doesn't know. Those keep the name they were loaded with.
+//Directory checksums added with AddDir are never written.
+func (c *KnownSums) ExportBSD(w io.Writer, hash *crypto.Hash) error {
+	c.RLock()
+	defer c.RUnlock()
+	for _, sum := range withKeyID(c.knownSums, c.KeyID) {
+		if hash != nil && (sum.Hash != *hash || sum.Hash == 0) || sum.isDir() {
+			continue
+		}
+		hashName := bsdHashName(sum.Hash)
+		if sum.Hash == 0 && sum.HashName != "" {
+			hashName = sum.HashName
+		}
+		prefix, name := escapeManifestName(sum.Name)
+		_, err := fmt.Fprintf(w, "%s%s (%s) = %x\n", prefix, hashName, name, sum.Checksum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//scanManifest calls fn for each line of r that isn't blank or a comment
+func scanManifest(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := fn(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+//bsdHashName returns the algorithm name used in BSD manifests. sha3_256 becomes SHA3-256 and blake2b_512 becomes
+//BLAKE2b-512. Tree hashes keep their hashnames name.
+func bsdHashName(hash crypto.Hash) string {
+	name := hashnames.HashName(hash)
+	if _, _, ok := hashnames.TreeHashParams(hash); ok {
+		return name
+	}
+	name = strings.ToUpper(strings.Replace(name, "_", "-", -1))
+	return strings.Replace(strings.Replace(name, "BLAKE2B", "BLAKE2b", 1), "BLAKE2S", "BLAKE2s", 1)
+}
+
+//lookupBSDHash is the reverse of bsdHashName. It also accepts BLAKE2b and BLAKE2s without a size the way b2sum
+//writes them.
+func lookupBSDHash(name string) crypto.Hash {
+	if hash := hashnames.LookupHash(name); hash != 0 {
+		return hash
+	}
+	name = strings.ToLower(strings.Replace(name, "-", "_", -1))
+	switch name {
+	case "blake2b":
+		name = "blake2b_512"
+	case "blake2s":
+		name = "blake2s_256"
+	}
+	return hashnames.LookupHash(name)
+}
+
+//trimEscapePrefix removes the leading backslash coreutils uses to mark lines with escaped names
+func trimEscapePrefix(line string) (string, bool) {
+	if strings.HasPrefix(line, `\`) {
+		return line[1:], true
+	}
+	return line, false
+}
+
+var manifestNameEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+var manifestNameUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+//escapeManifestName escapes name the way coreutils does and returns the line prefix that marks it as escaped
+func escapeManifestName(name string) (prefix, escaped string) {
+	if !strings.ContainsAny(name, "\\\n\r") {
+		return "", name
+	}
+	return `\`, manifestNameEscaper.Replace(name)
+}
+
+func unescapeManifestName(name string) string {
+	return manifestNameUnescaper.Replace(name)
+}
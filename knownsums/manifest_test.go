@@ -0,0 +1,200 @@
+package knownsums
+
+import (
+	"bytes"
+	"crypto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnownSums_ImportCoreutils(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		manifest := strings.Join([]string{
+			knownHexSums["sha256"]["foo"] + "  foo.txt",
+			knownHexSums["sha256"][""] + " *dir/empty file",
+			"",
+			`\` + knownHexSums["sha256"]["foo"] + `  back\\slash\nnewline`,
+		}, "\n")
+		knownSums := &KnownSums{}
+		err := knownSums.ImportCoreutils(strings.NewReader(manifest), crypto.SHA256)
+		require.NoError(t, err)
+		want := []*knownSum{
+			{
+				Name:     "foo.txt",
+				Hash:     crypto.SHA256,
+				Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+			},
+			{
+				Name:     "dir/empty file",
+				Hash:     crypto.SHA256,
+				Checksum: mustHexDecode(t, knownHexSums["sha256"][""]),
+			},
+			{
+				Name:     "back\\slash\nnewline",
+				Hash:     crypto.SHA256,
+				Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+			},
+		}
+		assert.Equal(t, want, knownSums.knownSums)
+	})
+
+	t.Run("bad line", func(t *testing.T) {
+		manifest := knownHexSums["md5"]["foo"] + "  foo.txt\n" + knownHexSums["md5"]["foo"] + "foo.txt\n"
+		knownSums := &KnownSums{}
+		err := knownSums.ImportCoreutils(strings.NewReader(manifest), crypto.MD5)
+		assert.EqualError(t, err, "line 2: improperly formatted checksum line")
+		assert.Empty(t, knownSums.knownSums)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		manifest := knownHexSums["md5"]["foo"] + "  foo.txt\n" + knownHexSums["md5"][""] + "  foo.txt\n"
+		knownSums := &KnownSums{}
+		err := knownSums.ImportCoreutils(strings.NewReader(manifest), crypto.MD5)
+		assert.EqualError(t, err, "cannot add duplicate name and hash")
+		assert.Empty(t, knownSums.knownSums)
+	})
+}
+
+func TestKnownSums_ImportBSD(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		manifest := strings.Join([]string{
+			"SHA256 (foo.txt) = " + knownHexSums["sha256"]["foo"],
+			"MD5 (a (weird) name) = " + knownHexSums["md5"]["foo"],
+			"SHA512-256 (foo.txt) = abcd",
+			"BLAKE2b (foo.txt) = abcd",
+			"tree-sha256-1MiB (foo.txt) = abcd",
+		}, "\n")
+		knownSums := &KnownSums{}
+		err := knownSums.ImportBSD(strings.NewReader(manifest))
+		require.NoError(t, err)
+		var got []string
+		for _, sum := range knownSums.knownSums {
+			got = append(got, sum.Name+" "+bsdHashName(sum.Hash))
+		}
+		assert.Equal(t, []string{
+			"foo.txt SHA256",
+			"a (weird) name MD5",
+			"foo.txt SHA512-256",
+			"foo.txt BLAKE2b-512",
+			"foo.txt tree-sha256-1MiB",
+		}, got)
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		knownSums := &KnownSums{}
+		err := knownSums.ImportBSD(strings.NewReader("BOGUS (foo.txt) = abcd"))
+		assert.EqualError(t, err, `line 1: unknown algorithm "BOGUS"`)
+		assert.Empty(t, knownSums.knownSums)
+	})
+
+	t.Run("bad line", func(t *testing.T) {
+		knownSums := &KnownSums{}
+		err := knownSums.ImportBSD(strings.NewReader("SHA256 foo.txt = abcd"))
+		assert.EqualError(t, err, "line 1: improperly formatted checksum line")
+	})
+}
+
+func TestKnownSums_ExportCoreutils(t *testing.T) {
+	knownSums := &KnownSums{
+		knownSums: []*knownSum{
+			{Name: "foo.txt", Hash: crypto.SHA256, Checksum: []byte("foo")},
+			{Name: "foo.txt", Hash: crypto.MD5, Checksum: []byte("foo")},
+			{Name: "new\nline", Hash: crypto.SHA256, Checksum: []byte("bar")},
+			{Name: "keyed", Hash: crypto.SHA256, Checksum: []byte("bar"), KeyID: "abc"},
+		},
+	}
+	var buf bytes.Buffer
+	err := knownSums.ExportCoreutils(&buf, crypto.SHA256)
+	require.NoError(t, err)
+	want := "666f6f  foo.txt\n\\626172  new\\nline\n"
+	assert.Equal(t, want, buf.String())
+
+	roundTrip := &KnownSums{}
+	err = roundTrip.ImportCoreutils(&buf, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, knownSums.knownSums[0], roundTrip.knownSums[0])
+	assert.Equal(t, knownSums.knownSums[2], roundTrip.knownSums[1])
+}
+
+func TestKnownSums_ExportBSD(t *testing.T) {
+	knownSums := &KnownSums{
+		knownSums: []*knownSum{
+			{Name: "foo.txt", Hash: crypto.SHA256, Checksum: []byte("foo")},
+			{Name: "foo.txt", Hash: crypto.SHA3_256, Checksum: []byte("foo")},
+			{Name: "bar.txt", Hash: crypto.BLAKE2b_256, Checksum: []byte("bar")},
+		},
+	}
+	var buf bytes.Buffer
+	err := knownSums.ExportBSD(&buf, nil)
+	require.NoError(t, err)
+	want := `SHA256 (foo.txt) = 666f6f
+SHA3-256 (foo.txt) = 666f6f
+BLAKE2b-256 (bar.txt) = 626172
+`
+	assert.Equal(t, want, buf.String())
+
+	roundTrip := &KnownSums{}
+	err = roundTrip.ImportBSD(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, knownSums.knownSums, roundTrip.knownSums)
+
+	buf.Reset()
+	hash := crypto.SHA3_256
+	err = knownSums.ExportBSD(&buf, &hash)
+	require.NoError(t, err)
+	assert.Equal(t, "SHA3-256 (foo.txt) = 666f6f\n", buf.String())
+}
+
+func TestKnownSums_Export_unknownHashName(t *testing.T) {
+	knownSums := &KnownSums{
+		knownSums: []*knownSum{
+			{Name: "foo.txt", Hash: crypto.SHA256, Checksum: []byte("foo")},
+			{Name: "bar.txt", HashName: "sha256_custom", Checksum: []byte("bar")},
+			{Name: "baz.txt", HashName: "other_custom", Checksum: []byte("baz")},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := knownSums.ExportBSD(&buf, nil)
+	require.NoError(t, err)
+	want := `SHA256 (foo.txt) = 666f6f
+sha256_custom (bar.txt) = 626172
+other_custom (baz.txt) = 62617a
+`
+	assert.Equal(t, want, buf.String())
+
+	buf.Reset()
+	hash := crypto.Hash(0)
+	err = knownSums.ExportBSD(&buf, &hash)
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+
+	err = knownSums.ExportCoreutils(&buf, 0)
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestKnownSums_Export_skipsDirs(t *testing.T) {
+	knownSums := &KnownSums{
+		knownSums: []*knownSum{
+			{Name: "foo.txt", Hash: crypto.SHA256, Checksum: []byte("foo")},
+			{Name: "dir", Hash: crypto.SHA256, Checksum: []byte("dir"), Files: []fileSum{
+				{Name: "bar.txt", Checksum: []byte("bar")},
+			}},
+			{Name: "empty", Hash: crypto.SHA256, Checksum: []byte("empty"), Files: []fileSum{}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := knownSums.ExportCoreutils(&buf, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, "666f6f  foo.txt\n", buf.String())
+
+	buf.Reset()
+	err = knownSums.ExportBSD(&buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SHA256 (foo.txt) = 666f6f\n", buf.String())
+}
@@ -0,0 +1,97 @@
+package knownsums
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+	"github.com/WillAbides/checksum/sumchecker"
+)
+
+//ErrNoStrongMatch is returned when UnavailableRequireStrong is set and no known sum with a strong hash was checked
+var ErrNoStrongMatch = errors.New("no known sum with a strong hash was checked")
+
+//UnavailablePolicy decides how validation treats known sums whose hash isn't available in this binary
+type UnavailablePolicy int
+
+const (
+	//UnavailableSkip skips known sums with unavailable hashes. Validation succeeds when all the others match.
+	UnavailableSkip UnavailablePolicy = iota
+	//UnavailableFail fails validation when any of the known sums has an unavailable hash
+	UnavailableFail
+	//UnavailableRequireStrong skips known sums with unavailable hashes but fails validation unless at least one of
+	//the known sums that were checked uses a hash with sumchecker.StrengthStrong.
+	UnavailableRequireStrong
+)
+
+var unavailablePolicyNames = map[UnavailablePolicy]string{
+	UnavailableSkip:          "skip",
+	UnavailableFail:          "fail",
+	UnavailableRequireStrong: "require-strong",
+}
+
+func (p UnavailablePolicy) String() string {
+	if name, ok := unavailablePolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("UnavailablePolicy(%d)", int(p))
+}
+
+//LookupUnavailablePolicy returns the UnavailablePolicy with the given name. It returns false if there isn't one.
+func LookupUnavailablePolicy(name string) (UnavailablePolicy, bool) {
+	for policy, policyName := range unavailablePolicyNames {
+		if policyName == name {
+			return policy, true
+		}
+	}
+	return 0, false
+}
+
+//checkPolicy returns an error if KnownSums' UnavailablePolicy doesn't allow validating sums with only the available
+//ones
+func (c *KnownSums) checkPolicy(sums, available []*knownSum) error {
+	switch c.UnavailablePolicy {
+	case UnavailableFail:
+		if len(available) != len(sums) {
+			return ErrUnavailableHash
+		}
+	case UnavailableRequireStrong:
+		for _, sum := range available {
+			if strongHash(sum.Hash) {
+				return nil
+			}
+		}
+		return ErrNoStrongMatch
+	}
+	return nil
+}
+
+//checkHash returns an error if sums with hash can't be calculated or are weaker than KnownSums' MinStrength
+func (c *KnownSums) checkHash(hash crypto.Hash) error {
+	if !hashnames.Available(hash) {
+		return ErrUnavailableHash
+	}
+	return sumchecker.CheckStrength(hash, c.MinStrength)
+}
+
+//trusted returns true if sum's hash is at least as strong as KnownSums' MinStrength
+func (c *KnownSums) trusted(sum *knownSum) bool {
+	return sumchecker.HashStrength(sum.Hash) >= c.MinStrength
+}
+
+//trustedSums returns the sums that are at least as strong as KnownSums' MinStrength
+func (c *KnownSums) trustedSums(sums []*knownSum) []*knownSum {
+	result := make([]*knownSum, 0, len(sums))
+	for _, sum := range sums {
+		if c.trusted(sum) {
+			result = append(result, sum)
+		}
+	}
+	return result
+}
+
+//strongHash returns true for hashes with sumchecker.StrengthStrong
+func strongHash(hash crypto.Hash) bool {
+	return sumchecker.HashStrength(hash) == sumchecker.StrengthStrong
+}
@@ -0,0 +1,199 @@
+package knownsums
+
+import (
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnownSums_UnavailablePolicy(t *testing.T) {
+	name := "sumname"
+	unavailable := &knownSum{
+		Name:     name,
+		Hash:     999,
+		Checksum: []byte("deadbeef"),
+	}
+	sha256Sum := &knownSum{
+		Name:     name,
+		Hash:     crypto.SHA256,
+		Checksum: mustHexDecode(t, knownHexSums["sha256"]["foo"]),
+	}
+	md5Sum := &knownSum{
+		Name:     name,
+		Hash:     crypto.MD5,
+		Checksum: mustHexDecode(t, knownHexSums["md5"]["foo"]),
+	}
+
+	for _, td := range []struct {
+		name   string
+		sums   []*knownSum
+		policy UnavailablePolicy
+		err    error
+	}{
+		{name: "skip only unavailable", sums: []*knownSum{unavailable}, policy: UnavailableSkip, err: ErrUnavailableHash},
+		{name: "skip with available", sums: []*knownSum{sha256Sum, unavailable}, policy: UnavailableSkip},
+		{name: "fail with available", sums: []*knownSum{sha256Sum, unavailable}, policy: UnavailableFail, err: ErrUnavailableHash},
+		{name: "fail all available", sums: []*knownSum{sha256Sum, md5Sum}, policy: UnavailableFail},
+		{name: "require strong with strong", sums: []*knownSum{sha256Sum, unavailable}, policy: UnavailableRequireStrong},
+		{name: "require strong with weak", sums: []*knownSum{md5Sum, unavailable}, policy: UnavailableRequireStrong, err: ErrNoStrongMatch},
+		{name: "require strong only unavailable", sums: []*knownSum{unavailable}, policy: UnavailableRequireStrong, err: ErrUnavailableHash},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			knownSums := &KnownSums{
+				Checker:           sumchecker.New(nil),
+				UnavailablePolicy: td.policy,
+				knownSums:         td.sums,
+			}
+			result, err := knownSums.ValidateDetailed(name, nil, strings.NewReader("foo"))
+			require.NoError(t, err)
+			assert.Equal(t, td.err, result.Err())
+			assert.Equal(t, td.err == nil, result.OK())
+			got, err := knownSums.Validate(name, nil, []byte("foo"))
+			assert.NoError(t, err)
+			assert.Equal(t, td.err == nil, got)
+		})
+	}
+
+	t.Run("skipped entries", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker:   sumchecker.New(nil),
+			knownSums: []*knownSum{sha256Sum, unavailable},
+		}
+		result, err := knownSums.ValidateDetailed(name, nil, strings.NewReader("foo"))
+		require.NoError(t, err)
+		assert.Equal(t, []EntryResult{
+			{
+				Hash:     999,
+				Status:   StatusUnavailableHash,
+				Expected: []byte("deadbeef"),
+			},
+		}, result.Skipped())
+	})
+
+	t.Run("ValidateDir", func(t *testing.T) {
+		dir, teardown := tmpDir(t, map[string]string{"foo": "foo"})
+		defer teardown()
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		require.NoError(t, knownSums.AddDir(name, crypto.SHA256, dir))
+		require.NoError(t, knownSums.addKnownSums(
+			&knownSum{Name: name, Hash: 999, Checksum: []byte("deadbeef"), Files: []fileSum{}},
+			&knownSum{Name: "unavailable", Hash: 999, Checksum: []byte("deadbeef"), Files: []fileSum{}},
+		))
+		ok, _, err := knownSums.ValidateDir(name, nil, dir)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, _, err = knownSums.ValidateDir("unavailable", nil, dir)
+		assert.EqualError(t, err, "error validating known sum unavailable: hash is not available")
+		assert.False(t, ok)
+		knownSums.UnavailablePolicy = UnavailableFail
+		ok, _, err = knownSums.ValidateDir(name, nil, dir)
+		assert.EqualError(t, err, "error validating known sum sumname: hash is not available")
+		assert.False(t, ok)
+	})
+
+	t.Run("ValidateRange", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker:           sumchecker.New(nil),
+			UnavailablePolicy: UnavailableRequireStrong,
+		}
+		require.NoError(t, knownSums.AddChunked(name, crypto.MD5, 2, strings.NewReader("foo")))
+		ok, err := knownSums.ValidateRange(name, nil, 0, []byte("fo"))
+		assert.EqualError(t, err, "error validating range of known sum sumname: no known sum with a strong hash was checked")
+		assert.False(t, ok)
+	})
+
+	t.Run("ValidateRange unavailable", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+			knownSums: []*knownSum{
+				{Name: name, Hash: 999, Size: 3, ChunkSize: 2, Chunks: [][]byte{[]byte("dead"), []byte("beef")}},
+			},
+		}
+		ok, err := knownSums.ValidateRange(name, nil, 0, []byte("fo"))
+		assert.EqualError(t, err, "error validating range of known sum sumname: hash is not available")
+		assert.True(t, errors.Is(err, ErrUnavailableHash))
+		assert.False(t, ok)
+	})
+}
+
+func TestLookupUnavailablePolicy(t *testing.T) {
+	for _, policy := range []UnavailablePolicy{UnavailableSkip, UnavailableFail, UnavailableRequireStrong} {
+		got, ok := LookupUnavailablePolicy(policy.String())
+		assert.True(t, ok)
+		assert.Equal(t, policy, got)
+	}
+	_, ok := LookupUnavailablePolicy("bogus")
+	assert.False(t, ok)
+	assert.Equal(t, "UnavailablePolicy(9)", UnavailablePolicy(9).String())
+}
+
+func TestKnownSums_MinStrength(t *testing.T) {
+	name := "sumname"
+
+	t.Run("add", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker:     sumchecker.New(nil),
+			MinStrength: sumchecker.StrengthStrong,
+		}
+		err := knownSums.Add(name, crypto.MD5, []byte("foo"))
+		assert.EqualError(t, err, "md5 is broken: hash is weaker than the minimum strength")
+		assert.True(t, errors.Is(err, ErrWeakHash))
+		err = knownSums.AddPrecalculatedSum(name, crypto.SHA1, []byte("deadbeef"))
+		assert.True(t, errors.Is(err, ErrWeakHash))
+		_, err = knownSums.Upsert(name, crypto.MD5, []byte("foo"))
+		assert.True(t, errors.Is(err, ErrWeakHash))
+		_, err = knownSums.Set(name, crypto.MD5, []byte("deadbeef"))
+		assert.True(t, errors.Is(err, ErrWeakHash))
+		err = knownSums.ImportCoreutils(strings.NewReader(knownHexSums["md5"]["foo"]+"  foo\n"), crypto.MD5)
+		assert.True(t, errors.Is(err, ErrWeakHash))
+		assert.Empty(t, knownSums.knownSums)
+		assert.NoError(t, knownSums.Add(name, crypto.SHA256, []byte("foo")))
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		require.NoError(t, knownSums.Add(name, crypto.MD5, []byte("foo")))
+		require.NoError(t, knownSums.Add(name, crypto.SHA256, []byte("foo")))
+		require.NoError(t, knownSums.Add("weak", crypto.SHA1, []byte("foo")))
+		knownSums.MinStrength = sumchecker.StrengthStrong
+
+		result, err := knownSums.ValidateDetailed(name, nil, strings.NewReader("foo"))
+		require.NoError(t, err)
+		assert.True(t, result.OK())
+		assert.Equal(t, []EntryResult{
+			{
+				Hash:     crypto.MD5,
+				Status:   StatusWeakHash,
+				Expected: mustHexDecode(t, knownHexSums["md5"]["foo"]),
+			},
+		}, result.Untrusted())
+
+		result, err = knownSums.ValidateDetailed("weak", nil, strings.NewReader("foo"))
+		require.NoError(t, err)
+		assert.False(t, result.OK())
+		assert.Equal(t, ErrWeakHash, result.Err())
+		assert.Equal(t, "weak hash", result.Entries[0].Status.String())
+	})
+
+	t.Run("ValidateDir", func(t *testing.T) {
+		dir, teardown := tmpDir(t, map[string]string{"foo": "foo"})
+		defer teardown()
+		knownSums := &KnownSums{
+			Checker: sumchecker.New(nil),
+		}
+		require.NoError(t, knownSums.AddDir(name, crypto.MD5, dir))
+		knownSums.MinStrength = sumchecker.StrengthWeak
+		ok, _, err := knownSums.ValidateDir(name, nil, dir)
+		assert.EqualError(t, err, "error validating known sum sumname: hash is weaker than the minimum strength")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,133 @@
+package knownsums
+
+import (
+	"crypto"
+	"fmt"
+)
+
+//Status is the outcome of validating one known sum
+type Status int
+
+const (
+	//StatusMatched means the data's checksum matched the known sum
+	StatusMatched Status = iota + 1
+	//StatusMismatched means the data's checksum did not match the known sum
+	StatusMismatched
+	//StatusUnavailableHash means the known sum's hash isn't available in this binary, so it wasn't checked
+	StatusUnavailableHash
+	//StatusNoEntry means there is no known sum for the name and hash
+	StatusNoEntry
+	//StatusWeakHash means the known sum's hash is weaker than MinStrength, so it wasn't checked
+	StatusWeakHash
+)
+
+var statusNames = map[Status]string{
+	StatusMatched:         "matched",
+	StatusMismatched:      "mismatched",
+	StatusUnavailableHash: "unavailable hash",
+	StatusNoEntry:         "no entry",
+	StatusWeakHash:        "weak hash",
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+//EntryResult is the result of validating data against one known sum
+type EntryResult struct {
+	Hash crypto.Hash
+	//HashName is only set when Hash is 0 because the known sum was loaded with a hash name that hashnames doesn't know.
+	HashName string
+	Status   Status
+	Expected []byte
+	//Actual is the data's checksum. It is nil unless Status is StatusMatched or StatusMismatched.
+	Actual []byte
+}
+
+//ValidationResult is the result of ValidateDetailed
+type ValidationResult struct {
+	Name    string
+	Entries []EntryResult
+	//Policy is the UnavailablePolicy of the KnownSums that created the result
+	Policy UnavailablePolicy
+}
+
+//OK returns true when at least one known sum matched, none mismatched and Policy is satisfied.
+//This is the result Validate returns.
+func (r *ValidationResult) OK() bool {
+	matched, strongMatched, unavailable := false, false, false
+	for _, entry := range r.Entries {
+		switch entry.Status {
+		case StatusMatched:
+			matched = true
+			strongMatched = strongMatched || strongHash(entry.Hash)
+		case StatusMismatched:
+			return false
+		case StatusUnavailableHash:
+			unavailable = true
+		}
+	}
+	switch r.Policy {
+	case UnavailableFail:
+		return matched && !unavailable
+	case UnavailableRequireStrong:
+		return strongMatched
+	}
+	return matched
+}
+
+//Skipped returns the entries that weren't checked because their hash isn't available
+func (r *ValidationResult) Skipped() []EntryResult {
+	var skipped []EntryResult
+	for _, entry := range r.Entries {
+		if entry.Status == StatusUnavailableHash {
+			skipped = append(skipped, entry)
+		}
+	}
+	return skipped
+}
+
+//Untrusted returns the entries that weren't checked because their hash is weaker than MinStrength
+func (r *ValidationResult) Untrusted() []EntryResult {
+	var untrusted []EntryResult
+	for _, entry := range r.Entries {
+		if entry.Status == StatusWeakHash {
+			untrusted = append(untrusted, entry)
+		}
+	}
+	return untrusted
+}
+
+//Err returns nil when OK returns true. Otherwise it returns a *MismatchError for the first known sum that mismatched,
+//ErrUnavailableHash when known sums couldn't be checked because of unavailable hashes, ErrNoStrongMatch when Policy
+//requires a strong hash and none matched, ErrWeakHash when every known sum was weaker than MinStrength, or ErrNotFound
+//when there were no known sums.
+func (r *ValidationResult) Err() error {
+	if r.OK() {
+		return nil
+	}
+	matched := false
+	for _, entry := range r.Entries {
+		if entry.Status == StatusMismatched {
+			return &MismatchError{
+				Name:     r.Name,
+				Hash:     entry.Hash,
+				Expected: entry.Expected,
+				Actual:   entry.Actual,
+			}
+		}
+		matched = matched || entry.Status == StatusMatched
+	}
+	switch {
+	case len(r.Skipped()) > 0 && (r.Policy == UnavailableFail || !matched):
+		return ErrUnavailableHash
+	case matched:
+		return ErrNoStrongMatch
+	case len(r.Untrusted()) > 0:
+		return ErrWeakHash
+	}
+	return ErrNotFound
+}
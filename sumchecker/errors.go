@@ -0,0 +1,46 @@
+package sumchecker
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+)
+
+var (
+	//ErrUnregisteredHash is returned when a checksum is requested for a hash that isn't linked into the binary
+	ErrUnregisteredHash = errors.New("unregistered hash")
+	//ErrMismatch matches any *MismatchError with errors.Is. Wrap it when a mismatch is detected without the sums.
+	ErrMismatch = errors.New("checksum mismatch")
+)
+
+//MismatchError is returned when a checksum doesn't match the expected checksum
+type MismatchError struct {
+	//Name is the name of the known sum that didn't match. It is empty when there isn't a name.
+	Name     string
+	Hash     crypto.Hash
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *MismatchError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(ErrMismatch.Error())
+	if e.Name != "" {
+		fmt.Fprintf(&sb, " for %s", e.Name)
+	}
+	if e.Hash != 0 {
+		fmt.Fprintf(&sb, " using %s", hashnames.HashName(e.Hash))
+	}
+	if len(e.Expected) > 0 || len(e.Actual) > 0 {
+		fmt.Fprintf(&sb, ": expected %x but got %x", e.Expected, e.Actual)
+	}
+	return sb.String()
+}
+
+//Is makes errors.Is(err, ErrMismatch) true for a *MismatchError
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrMismatch
+}
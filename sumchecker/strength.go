@@ -0,0 +1,106 @@
+package sumchecker
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+)
+
+//ErrWeakHash is returned when a hash is weaker than the minimum strength
+var ErrWeakHash = errors.New("hash is weaker than the minimum strength")
+
+//Strength classifies hashes by how much their checksums can be trusted
+type Strength int
+
+const (
+	//StrengthBroken is for hashes with practical collision attacks like md4, md5 and sha1.
+	//Hashes that aren't classified are also considered broken.
+	StrengthBroken Strength = iota
+	//StrengthWeak is for hashes without practical attacks but with small margins like ripemd160
+	StrengthWeak
+	//StrengthStrong is for hashes that are currently recommended like sha2, sha3 and blake2
+	StrengthStrong
+)
+
+var strengthNames = map[Strength]string{
+	StrengthBroken: "broken",
+	StrengthWeak:   "weak",
+	StrengthStrong: "strong",
+}
+
+func (s Strength) String() string {
+	if name, ok := strengthNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Strength(%d)", int(s))
+}
+
+//LookupStrength returns the Strength with the given name. It returns false if there isn't one.
+func LookupStrength(name string) (Strength, bool) {
+	for strength, strengthName := range strengthNames {
+		if strengthName == name {
+			return strength, true
+		}
+	}
+	return 0, false
+}
+
+var strengthsMux sync.RWMutex
+
+var hashStrengths = map[crypto.Hash]Strength{
+	crypto.MD4:         StrengthBroken,
+	crypto.MD5:         StrengthBroken,
+	crypto.SHA1:        StrengthBroken,
+	crypto.MD5SHA1:     StrengthBroken,
+	crypto.RIPEMD160:   StrengthWeak,
+	crypto.SHA224:      StrengthStrong,
+	crypto.SHA256:      StrengthStrong,
+	crypto.SHA384:      StrengthStrong,
+	crypto.SHA512:      StrengthStrong,
+	crypto.SHA512_224:  StrengthStrong,
+	crypto.SHA512_256:  StrengthStrong,
+	crypto.SHA3_224:    StrengthStrong,
+	crypto.SHA3_256:    StrengthStrong,
+	crypto.SHA3_384:    StrengthStrong,
+	crypto.SHA3_512:    StrengthStrong,
+	crypto.BLAKE2s_256: StrengthStrong,
+	crypto.BLAKE2b_256: StrengthStrong,
+	crypto.BLAKE2b_384: StrengthStrong,
+	crypto.BLAKE2b_512: StrengthStrong,
+}
+
+//HashStrength returns the Strength of hsh. Tree hashes are as strong as their base hash.
+func HashStrength(hsh crypto.Hash) Strength {
+	if base, _, ok := hashnames.TreeHashParams(hsh); ok {
+		hsh = base
+	}
+	strengthsMux.RLock()
+	defer strengthsMux.RUnlock()
+	return hashStrengths[hsh]
+}
+
+//SetHashStrength sets the Strength of hsh. Use it to classify hashes added with hashnames.Register, which are
+//otherwise considered broken, or to override the built-in classification.
+func SetHashStrength(hsh crypto.Hash, strength Strength) {
+	strengthsMux.Lock()
+	defer strengthsMux.Unlock()
+	hashStrengths[hsh] = strength
+}
+
+//CheckStrength returns an error wrapping ErrWeakHash if hsh is weaker than min
+func CheckStrength(hsh crypto.Hash, min Strength) error {
+	if HashStrength(hsh) < min {
+		return fmt.Errorf("%s is %s: %w", hashnames.HashName(hsh), HashStrength(hsh), ErrWeakHash)
+	}
+	return nil
+}
+
+//WithMinStrength makes the Checker refuse to calculate or validate checksums with hashes weaker than min
+func WithMinStrength(min Strength) Option {
+	return func(c *Checker) {
+		c.minStrength = min
+	}
+}
@@ -0,0 +1,75 @@
+package sumchecker_test
+
+import (
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashStrength(t *testing.T) {
+	for hsh, want := range map[crypto.Hash]sumchecker.Strength{
+		crypto.MD4:                              sumchecker.StrengthBroken,
+		crypto.MD5:                              sumchecker.StrengthBroken,
+		crypto.SHA1:                             sumchecker.StrengthBroken,
+		crypto.MD5SHA1:                          sumchecker.StrengthBroken,
+		crypto.RIPEMD160:                        sumchecker.StrengthWeak,
+		crypto.SHA224:                           sumchecker.StrengthStrong,
+		crypto.SHA256:                           sumchecker.StrengthStrong,
+		crypto.SHA384:                           sumchecker.StrengthStrong,
+		crypto.SHA512:                           sumchecker.StrengthStrong,
+		crypto.SHA512_224:                       sumchecker.StrengthStrong,
+		crypto.SHA512_256:                       sumchecker.StrengthStrong,
+		crypto.SHA3_224:                         sumchecker.StrengthStrong,
+		crypto.SHA3_256:                         sumchecker.StrengthStrong,
+		crypto.SHA3_384:                         sumchecker.StrengthStrong,
+		crypto.SHA3_512:                         sumchecker.StrengthStrong,
+		crypto.BLAKE2s_256:                      sumchecker.StrengthStrong,
+		crypto.BLAKE2b_256:                      sumchecker.StrengthStrong,
+		crypto.BLAKE2b_384:                      sumchecker.StrengthStrong,
+		crypto.BLAKE2b_512:                      sumchecker.StrengthStrong,
+		hashnames.TreeHash(crypto.SHA256, 1024): sumchecker.StrengthStrong,
+		hashnames.TreeHash(crypto.SHA1, 1024):   sumchecker.StrengthBroken,
+		hashnames.TreeHash(crypto.RIPEMD160, 1024): sumchecker.StrengthWeak,
+		999: sumchecker.StrengthBroken,
+	} {
+		assert.Equal(t, want, sumchecker.HashStrength(hsh), hashnames.HashName(hsh))
+	}
+}
+
+func TestLookupStrength(t *testing.T) {
+	for _, strength := range []sumchecker.Strength{sumchecker.StrengthBroken, sumchecker.StrengthWeak, sumchecker.StrengthStrong} {
+		got, ok := sumchecker.LookupStrength(strength.String())
+		assert.True(t, ok)
+		assert.Equal(t, strength, got)
+	}
+	_, ok := sumchecker.LookupStrength("bogus")
+	assert.False(t, ok)
+	assert.Equal(t, "Strength(9)", sumchecker.Strength(9).String())
+}
+
+func TestWithMinStrength(t *testing.T) {
+	checker := sumchecker.New(nil, sumchecker.WithMinStrength(sumchecker.StrengthStrong))
+	got, err := checker.ChecksumReader(crypto.SHA256, strings.NewReader("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, mustHexDecode(t, knownHexSums[crypto.SHA256]["foo"]), got)
+
+	_, err = checker.ChecksumReader(crypto.MD5, strings.NewReader("foo"))
+	assert.EqualError(t, err, "md5 is broken: hash is weaker than the minimum strength")
+	assert.True(t, errors.Is(err, sumchecker.ErrWeakHash))
+
+	ok, err := checker.ValidateReader(crypto.SHA1, mustHexDecode(t, knownHexSums[crypto.SHA1]["foo"]), strings.NewReader("foo"))
+	assert.True(t, errors.Is(err, sumchecker.ErrWeakHash))
+	assert.False(t, ok)
+
+	_, err = checker.MultiChecksum([]crypto.Hash{crypto.SHA256, crypto.MD5}, strings.NewReader("foo"))
+	assert.True(t, errors.Is(err, sumchecker.ErrWeakHash))
+
+	_, err = checker.ChecksumReader(hashnames.TreeHash(crypto.MD5, 1024), strings.NewReader("foo"))
+	assert.True(t, errors.Is(err, sumchecker.ErrWeakHash))
+}
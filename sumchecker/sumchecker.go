@@ -2,35 +2,195 @@ package sumchecker
 
 import (
 	"bytes"
+	"context"
 	"crypto"
-	"fmt"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"hash"
+	"io"
+	"sync"
+
+	"github.com/WillAbides/checksum/internal/ctxio"
+	"github.com/WillAbides/checksum/knownsums/hashnames"
 )
 
+//HashRunner calls a func with a hash.Hash for a crypto.Hash. Checker only passes crypto.Hashes that are linked into the
+//binary to WithHash, so implementations can use crypto.Hash.New.
 type HashRunner interface {
 	WithHash(crypto.Hash, func(hash.Hash) error) error
 }
 
+//HashFuncRunner is a HashRunner that can also run the hashes added with hashnames.Register. Those are identified by
+//crypto.Hash values that crypto.Hash.New panics on, so Checker resolves newHash with hashnames.HashFunc and only passes
+//them to runners that implement HashFuncRunner. Other runners get ErrUnregisteredHash for registered hashes.
+type HashFuncRunner interface {
+	HashRunner
+	WithHashFunc(hsh crypto.Hash, newHash func() hash.Hash, fn func(hash.Hash) error) error
+}
+
 type Checker struct {
-	runner HashRunner
+	runner       HashRunner
+	constantTime bool
+	minStrength  Strength
+}
+
+//Option configures a Checker
+type Option func(*Checker)
+
+//WithConstantTimeCompare makes the Checker compare checksums with crypto/subtle.ConstantTimeCompare.
+//Use it when the expected sum should be treated as a secret, such as with keyed hashes.
+func WithConstantTimeCompare() Option {
+	return func(c *Checker) {
+		c.constantTime = true
+	}
 }
 
 type defaultRunner struct{}
 
 func (r *defaultRunner) WithHash(hsh crypto.Hash, fn func(hash.Hash) error) error {
-	if !hsh.Available() {
-		return fmt.Errorf("unregistered hash")
+	return withHashFunc(r, hsh, fn)
+}
+
+func (r *defaultRunner) WithHashFunc(_ crypto.Hash, newHash func() hash.Hash, fn func(hash.Hash) error) error {
+	return fn(newHash())
+}
+
+//withHashFunc implements WithHash for the HashFuncRunners in this package
+func withHashFunc(runner HashFuncRunner, hsh crypto.Hash, fn func(hash.Hash) error) error {
+	newHash, ok := hashnames.HashFunc(hsh)
+	if !ok {
+		return ErrUnregisteredHash
+	}
+	return runner.WithHashFunc(hsh, newHash, fn)
+}
+
+type poolRunner struct {
+	mux   sync.RWMutex
+	pools map[crypto.Hash]*sync.Pool
+}
+
+//NewPoolRunner returns a HashRunner that reuses hash.Hash values from a sync.Pool for each crypto.Hash instead of
+//creating a new one for every checksum. It is safe for concurrent use.
+//The hash.Hash passed to WithHash's func must not be used after the func returns.
+func NewPoolRunner() HashRunner {
+	return &poolRunner{
+		pools: map[crypto.Hash]*sync.Pool{},
+	}
+}
+
+func (r *poolRunner) pool(hsh crypto.Hash, newHash func() hash.Hash) *sync.Pool {
+	r.mux.RLock()
+	pool := r.pools[hsh]
+	r.mux.RUnlock()
+	if pool != nil {
+		return pool
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	pool = r.pools[hsh]
+	if pool == nil {
+		pool = &sync.Pool{
+			New: func() interface{} {
+				return newHash()
+			},
+		}
+		r.pools[hsh] = pool
 	}
-	return fn(hsh.New())
+	return pool
 }
 
-func New(runner HashRunner) *Checker {
+func (r *poolRunner) WithHash(hsh crypto.Hash, fn func(hash.Hash) error) error {
+	return withHashFunc(r, hsh, fn)
+}
+
+func (r *poolRunner) WithHashFunc(hsh crypto.Hash, newHash func() hash.Hash, fn func(hash.Hash) error) error {
+	pool := r.pool(hsh, newHash)
+	hasher := pool.Get().(hash.Hash)
+	defer pool.Put(hasher)
+	hasher.Reset()
+	return fn(hasher)
+}
+
+type hmacRunner struct {
+	key []byte
+}
+
+//NewHMACRunner returns a HashRunner that wraps each crypto.Hash in an HMAC keyed with key.
+func NewHMACRunner(key []byte) HashRunner {
+	return &hmacRunner{
+		key: append([]byte(nil), key...),
+	}
+}
+
+func (r *hmacRunner) WithHash(hsh crypto.Hash, fn func(hash.Hash) error) error {
+	return withHashFunc(r, hsh, fn)
+}
+
+func (r *hmacRunner) WithHashFunc(_ crypto.Hash, newHash func() hash.Hash, fn func(hash.Hash) error) error {
+	return fn(hmac.New(newHash, r.key))
+}
+
+//KeyID returns an identifier for key that is safe to store alongside keyed checksums.
+//It is derived from a SHA-256 digest of the key and does not reveal the key itself.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func New(runner HashRunner, options ...Option) *Checker {
 	if runner == nil {
 		runner = new(defaultRunner)
 	}
-	return &Checker{
+	checker := &Checker{
 		runner: runner,
 	}
+	for _, option := range options {
+		option(checker)
+	}
+	return checker
+}
+
+//withHash is like HashRunner.WithHash but also handles tree hashes created with hashnames.TreeHash and refuses hashes
+//weaker than the Checker's minimum strength.
+//Chunks of a tree hash are hashed with the base hash from p's HashRunner. Hashes that aren't available, like tree
+//hashes with invalid chunk sizes, return ErrUnregisteredHash without calling the HashRunner.
+func (p *Checker) withHash(hsh crypto.Hash, fn func(hash.Hash) error) error {
+	err := CheckStrength(hsh, p.minStrength)
+	if err != nil {
+		return err
+	}
+	base, chunkSize, ok := hashnames.TreeHashParams(hsh)
+	if !ok {
+		return p.runWithHash(hsh, fn)
+	}
+	if !hashnames.Available(base) {
+		return ErrUnregisteredHash
+	}
+	tree := newTreeHash(p.runner, base, chunkSize)
+	err = fn(tree)
+	tree.wg.Wait()
+	if err == nil {
+		err = tree.error()
+	}
+	return err
+}
+
+//runWithHash calls p's HashRunner with hsh. Hashes added with hashnames.Register are only passed to HashFuncRunners.
+func (p *Checker) runWithHash(hsh crypto.Hash, fn func(hash.Hash) error) error {
+	newHash, ok := hashnames.HashFunc(hsh)
+	if !ok {
+		return ErrUnregisteredHash
+	}
+	if hsh.Available() {
+		return p.runner.WithHash(hsh, fn)
+	}
+	runner, ok := p.runner.(HashFuncRunner)
+	if !ok {
+		return ErrUnregisteredHash
+	}
+	return runner.WithHashFunc(hsh, newHash, fn)
 }
 
 var defaultChecker = New(nil)
@@ -40,9 +200,19 @@ func Checksum(hasher crypto.Hash, data []byte) ([]byte, error) {
 }
 
 func (p *Checker) Checksum(hasher crypto.Hash, data []byte) ([]byte, error) {
+	return p.ChecksumReader(hasher, bytes.NewReader(data))
+}
+
+//ChecksumReader calculates the checksum of everything read from r without holding it in memory.
+func ChecksumReader(hasher crypto.Hash, r io.Reader) ([]byte, error) {
+	return defaultChecker.ChecksumReader(hasher, r)
+}
+
+//ChecksumReader calculates the checksum of everything read from r without holding it in memory.
+func (p *Checker) ChecksumReader(hasher crypto.Hash, r io.Reader) ([]byte, error) {
 	var sum []byte
-	err := p.runner.WithHash(hasher, func(hsh hash.Hash) error {
-		_, e := hsh.Write(data)
+	err := p.withHash(hasher, func(hsh hash.Hash) error {
+		_, e := io.Copy(hsh, r)
 		if e != nil {
 			return e
 		}
@@ -52,14 +222,124 @@ func (p *Checker) Checksum(hasher crypto.Hash, data []byte) ([]byte, error) {
 	return sum, err
 }
 
+//ChecksumContext is like ChecksumReader but stops reading and returns ctx.Err() when ctx is done.
+func ChecksumContext(ctx context.Context, hasher crypto.Hash, r io.Reader) ([]byte, error) {
+	return defaultChecker.ChecksumContext(ctx, hasher, r)
+}
+
+//ChecksumContext is like ChecksumReader but stops reading and returns ctx.Err() when ctx is done.
+func (p *Checker) ChecksumContext(ctx context.Context, hasher crypto.Hash, r io.Reader) ([]byte, error) {
+	return p.ChecksumReader(hasher, ctxio.NewReader(ctx, r))
+}
+
+//MultiChecksum calculates checksums for each of hashes with a single read of r.
+func MultiChecksum(hashes []crypto.Hash, r io.Reader) (map[crypto.Hash][]byte, error) {
+	return defaultChecker.MultiChecksum(hashes, r)
+}
+
+//MultiChecksum calculates checksums for each of hashes with a single read of r.
+func (p *Checker) MultiChecksum(hashes []crypto.Hash, r io.Reader) (map[crypto.Hash][]byte, error) {
+	hashes = uniqueHashes(hashes)
+	hashers := make([]hash.Hash, len(hashes))
+	sums := make(map[crypto.Hash][]byte, len(hashes))
+	var withHashes func(i int) error
+	withHashes = func(i int) error {
+		if i < len(hashes) {
+			return p.withHash(hashes[i], func(hsh hash.Hash) error {
+				hashers[i] = hsh
+				return withHashes(i + 1)
+			})
+		}
+		writers := make([]io.Writer, len(hashers))
+		for j, hsh := range hashers {
+			writers[j] = hsh
+		}
+		_, e := io.Copy(io.MultiWriter(writers...), r)
+		if e != nil {
+			return e
+		}
+		for j, hsh := range hashers {
+			sums[hashes[j]] = hsh.Sum(nil)
+		}
+		return nil
+	}
+	err := withHashes(0)
+	if err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+func uniqueHashes(hashes []crypto.Hash) []crypto.Hash {
+	seen := make(map[crypto.Hash]bool, len(hashes))
+	result := make([]crypto.Hash, 0, len(hashes))
+	for _, hsh := range hashes {
+		if seen[hsh] {
+			continue
+		}
+		seen[hsh] = true
+		result = append(result, hsh)
+	}
+	return result
+}
+
 func ValidateChecksum(hasher crypto.Hash, wantSum []byte, data []byte) (bool, error) {
 	return defaultChecker.ValidateChecksum(hasher, wantSum, data)
 }
 
 func (p *Checker) ValidateChecksum(hasher crypto.Hash, wantSum []byte, data []byte) (bool, error) {
-	sum, err := p.Checksum(hasher, data)
+	return p.ValidateReader(hasher, wantSum, bytes.NewReader(data))
+}
+
+//ValidateReader returns true if the checksum of everything read from r matches wantSum.
+func ValidateReader(hasher crypto.Hash, wantSum []byte, r io.Reader) (bool, error) {
+	return defaultChecker.ValidateReader(hasher, wantSum, r)
+}
+
+//ValidateReader returns true if the checksum of everything read from r matches wantSum.
+func (p *Checker) ValidateReader(hasher crypto.Hash, wantSum []byte, r io.Reader) (bool, error) {
+	sum, err := p.ChecksumReader(hasher, r)
 	if err != nil {
 		return false, err
 	}
-	return bytes.Equal(wantSum, sum), nil
+	return p.Equal(wantSum, sum), nil
+}
+
+//VerifyReader is like ValidateReader but returns a *MismatchError instead of false when the checksum doesn't match.
+func VerifyReader(hasher crypto.Hash, wantSum []byte, r io.Reader) error {
+	return defaultChecker.VerifyReader(hasher, wantSum, r)
+}
+
+//VerifyReader is like ValidateReader but returns a *MismatchError instead of false when the checksum doesn't match.
+func (p *Checker) VerifyReader(hasher crypto.Hash, wantSum []byte, r io.Reader) error {
+	sum, err := p.ChecksumReader(hasher, r)
+	if err != nil {
+		return err
+	}
+	if !p.Equal(wantSum, sum) {
+		return &MismatchError{
+			Hash:     hasher,
+			Expected: append([]byte(nil), wantSum...),
+			Actual:   sum,
+		}
+	}
+	return nil
+}
+
+//ValidateContext is like ValidateReader but stops reading and returns ctx.Err() when ctx is done.
+func ValidateContext(ctx context.Context, hasher crypto.Hash, wantSum []byte, r io.Reader) (bool, error) {
+	return defaultChecker.ValidateContext(ctx, hasher, wantSum, r)
+}
+
+//ValidateContext is like ValidateReader but stops reading and returns ctx.Err() when ctx is done.
+func (p *Checker) ValidateContext(ctx context.Context, hasher crypto.Hash, wantSum []byte, r io.Reader) (bool, error) {
+	return p.ValidateReader(hasher, wantSum, ctxio.NewReader(ctx, r))
+}
+
+//Equal reports whether two checksums are equal using the Checker's comparison mode.
+func (p *Checker) Equal(wantSum, sum []byte) bool {
+	if p.constantTime {
+		return subtle.ConstantTimeCompare(wantSum, sum) == 1
+	}
+	return bytes.Equal(wantSum, sum)
 }
@@ -1,9 +1,15 @@
 package sumchecker_test
 
 import (
+	"context"
 	"crypto"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 
 	"github.com/WillAbides/checksum/sumchecker"
 	"github.com/stretchr/testify/assert"
@@ -59,3 +65,284 @@ func TestValidateChecksum(t *testing.T) {
 		}
 	})
 }
+
+func TestChecksumReader(t *testing.T) {
+	t.Run("known hashes", func(t *testing.T) {
+		for hsh, sums := range knownHexSums {
+			for input, wantHex := range sums {
+				got, err := sumchecker.ChecksumReader(hsh, iotest.OneByteReader(strings.NewReader(input)))
+				assert.NoError(t, err)
+				gotHex := hex.EncodeToString(got)
+				assert.Equal(t, wantHex, gotHex)
+			}
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		_, err := sumchecker.ChecksumReader(crypto.SHA256, iotest.TimeoutReader(strings.NewReader("foo")))
+		assert.Equal(t, iotest.ErrTimeout, err)
+	})
+}
+
+func TestValidateReader(t *testing.T) {
+	t.Run("known hashes", func(t *testing.T) {
+		for hsh, sums := range knownHexSums {
+			for input, wantHex := range sums {
+				want, err := hex.DecodeString(wantHex)
+				require.NoError(t, err)
+				got, err := sumchecker.ValidateReader(hsh, want, strings.NewReader(input))
+				assert.NoError(t, err)
+				assert.True(t, got)
+
+				got, err = sumchecker.ValidateReader(hsh, want, strings.NewReader(input+"bogus"))
+				assert.NoError(t, err)
+				assert.False(t, got)
+			}
+		}
+	})
+}
+
+func TestVerifyReader(t *testing.T) {
+	want := mustHexDecode(t, knownHexSums[crypto.SHA256]["foo"])
+	err := sumchecker.VerifyReader(crypto.SHA256, want, strings.NewReader("foo"))
+	assert.NoError(t, err)
+
+	err = sumchecker.VerifyReader(crypto.SHA256, want, strings.NewReader(""))
+	var mismatch *sumchecker.MismatchError
+	require.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, &sumchecker.MismatchError{
+		Hash:     crypto.SHA256,
+		Expected: want,
+		Actual:   mustHexDecode(t, knownHexSums[crypto.SHA256][""]),
+	}, mismatch)
+	assert.True(t, errors.Is(err, sumchecker.ErrMismatch))
+	assert.EqualError(t, err, fmt.Sprintf("checksum mismatch using sha256: expected %s but got %s",
+		knownHexSums[crypto.SHA256]["foo"], knownHexSums[crypto.SHA256][""]))
+
+	err = sumchecker.VerifyReader(999, want, strings.NewReader("foo"))
+	assert.True(t, errors.Is(err, sumchecker.ErrUnregisteredHash))
+}
+
+func TestMismatchError(t *testing.T) {
+	err := &sumchecker.MismatchError{Name: "foo"}
+	assert.EqualError(t, err, "checksum mismatch for foo")
+	assert.True(t, errors.Is(fmt.Errorf("wrapped: %w", err), sumchecker.ErrMismatch))
+}
+
+func TestMultiChecksum(t *testing.T) {
+	t.Run("known hashes", func(t *testing.T) {
+		hashes := make([]crypto.Hash, 0, len(knownHexSums))
+		for hsh := range knownHexSums {
+			hashes = append(hashes, hsh)
+		}
+		got, err := sumchecker.MultiChecksum(hashes, iotest.OneByteReader(strings.NewReader("foo")))
+		require.NoError(t, err)
+		assert.Len(t, got, len(hashes))
+		for hsh, sums := range knownHexSums {
+			assert.Equal(t, sums["foo"], hex.EncodeToString(got[hsh]))
+		}
+	})
+
+	t.Run("duplicate hashes", func(t *testing.T) {
+		got, err := sumchecker.MultiChecksum([]crypto.Hash{crypto.MD5, crypto.MD5}, strings.NewReader("foo"))
+		require.NoError(t, err)
+		assert.Equal(t, map[crypto.Hash][]byte{
+			crypto.MD5: mustHexDecode(t, knownHexSums[crypto.MD5]["foo"]),
+		}, got)
+	})
+
+	t.Run("unregistered hash", func(t *testing.T) {
+		got, err := sumchecker.MultiChecksum([]crypto.Hash{crypto.MD5, 999}, strings.NewReader("foo"))
+		assert.EqualError(t, err, "unregistered hash")
+		assert.True(t, errors.Is(err, sumchecker.ErrUnregisteredHash))
+		assert.Nil(t, got)
+	})
+}
+
+func mustHexDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestConstantTimeCompare(t *testing.T) {
+	checkers := map[string]*sumchecker.Checker{
+		"default":       sumchecker.New(nil),
+		"constant time": sumchecker.New(nil, sumchecker.WithConstantTimeCompare()),
+	}
+	for hsh, sums := range knownHexSums {
+		for input, wantHex := range sums {
+			want := mustHexDecode(t, wantHex)
+			for _, data := range []string{input, input + "bogus"} {
+				results := make(map[string]bool, len(checkers))
+				for checkerName, checker := range checkers {
+					got, err := checker.ValidateChecksum(hsh, want, []byte(data))
+					require.NoError(t, err)
+					results[checkerName] = got
+				}
+				assert.Equal(t, data == input, results["default"])
+				assert.Equal(t, results["default"], results["constant time"])
+			}
+		}
+	}
+
+	for _, td := range []struct {
+		a, b []byte
+	}{
+		{a: nil, b: nil},
+		{a: []byte{}, b: nil},
+		{a: []byte("foo"), b: []byte("foo")},
+		{a: []byte("foo"), b: []byte("fo")},
+		{a: []byte("foo"), b: []byte("bar")},
+		{a: nil, b: []byte("bar")},
+	} {
+		assert.Equal(t, checkers["default"].Equal(td.a, td.b), checkers["constant time"].Equal(td.a, td.b))
+	}
+}
+
+func TestNewHMACRunner(t *testing.T) {
+	// from RFC 4231 test case 2
+	key := []byte("Jefe")
+	data := []byte("what do ya want for nothing?")
+	want := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+
+	checker := sumchecker.New(sumchecker.NewHMACRunner(key))
+	got, err := checker.Checksum(crypto.SHA256, data)
+	require.NoError(t, err)
+	assert.Equal(t, want, hex.EncodeToString(got))
+
+	ok, err := checker.ValidateChecksum(crypto.SHA256, mustHexDecode(t, want), data)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = sumchecker.New(sumchecker.NewHMACRunner([]byte("bogus"))).ValidateChecksum(crypto.SHA256, mustHexDecode(t, want), data)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = checker.Checksum(999, data)
+	assert.EqualError(t, err, "unregistered hash")
+}
+
+func TestKeyID(t *testing.T) {
+	assert.Equal(t, "005725b48609c45e", sumchecker.KeyID([]byte("Jefe")))
+	assert.NotEqual(t, sumchecker.KeyID([]byte("Jefe")), sumchecker.KeyID([]byte("jefe")))
+}
+
+func TestNewPoolRunner(t *testing.T) {
+	checker := sumchecker.New(sumchecker.NewPoolRunner())
+
+	t.Run("known hashes", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			for hsh, sums := range knownHexSums {
+				for input, wantHex := range sums {
+					got, err := checker.Checksum(hsh, []byte(input))
+					assert.NoError(t, err)
+					assert.Equal(t, wantHex, hex.EncodeToString(got))
+				}
+			}
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for hsh, sums := range knownHexSums {
+					for input, wantHex := range sums {
+						got, err := checker.Checksum(hsh, []byte(input))
+						assert.NoError(t, err)
+						assert.Equal(t, wantHex, hex.EncodeToString(got))
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("unregistered hash", func(t *testing.T) {
+		_, err := checker.Checksum(999, []byte("foo"))
+		assert.EqualError(t, err, "unregistered hash")
+	})
+}
+
+func BenchmarkRunner(b *testing.B) {
+	data := []byte("a small blob of data")
+	runners := map[string]sumchecker.HashRunner{
+		"default": nil,
+		"pool":    sumchecker.NewPoolRunner(),
+	}
+	for _, hsh := range []crypto.Hash{crypto.SHA256, crypto.SHA512} {
+		for runnerName, runner := range runners {
+			checker := sumchecker.New(runner)
+			b.Run(fmt.Sprintf("%v/%s", hsh, runnerName), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_, err := checker.Checksum(hsh, data)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("%v/%s/parallel", hsh, runnerName), func(b *testing.B) {
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						_, err := checker.Checksum(hsh, data)
+						if err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+//cancelingReader cancels its context after the first read
+type cancelingReader struct {
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	r.reads++
+	r.cancel()
+	return len(p), nil
+}
+
+func TestChecksumContext(t *testing.T) {
+	t.Run("known hashes", func(t *testing.T) {
+		for hsh, sums := range knownHexSums {
+			for input, wantHex := range sums {
+				got, err := sumchecker.ChecksumContext(context.Background(), hsh, strings.NewReader(input))
+				assert.NoError(t, err)
+				assert.Equal(t, wantHex, hex.EncodeToString(got))
+			}
+		}
+	})
+
+	t.Run("canceled mid-stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		rdr := &cancelingReader{cancel: cancel}
+		got, err := sumchecker.ChecksumContext(ctx, crypto.SHA256, rdr)
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, got)
+		assert.Equal(t, 1, rdr.reads)
+	})
+}
+
+func TestValidateContext(t *testing.T) {
+	want := mustHexDecode(t, knownHexSums[crypto.SHA256]["foo"])
+	got, err := sumchecker.ValidateContext(context.Background(), crypto.SHA256, want, strings.NewReader("foo"))
+	assert.NoError(t, err)
+	assert.True(t, got)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err = sumchecker.ValidateContext(ctx, crypto.SHA256, want, strings.NewReader("foo"))
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, got)
+}
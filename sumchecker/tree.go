@@ -0,0 +1,201 @@
+package sumchecker
+
+import (
+	"crypto"
+	"hash"
+	"runtime"
+	"sync"
+)
+
+const (
+	treeLeafPrefix = 0x00
+	treeNodePrefix = 0x01
+	//maxTreeMemory limits the memory used by chunks that are being hashed. Fewer chunks are hashed in parallel when
+	//GOMAXPROCS chunks would use more.
+	maxTreeMemory = 256 << 20
+)
+
+//treeHash is a hash.Hash that splits its input into fixed-size chunks, hashes the chunks in parallel with a base hash
+//and combines the chunk sums into the root of a Merkle tree.
+//
+//Leaves are hash(0x00 || chunk) and interior nodes are hash(0x01 || left || right). When a level has an odd number of
+//nodes, the last one is promoted to the next level unchanged. Empty input is a single empty chunk.
+type treeHash struct {
+	runner    HashRunner
+	base      crypto.Hash
+	chunkSize int
+	sem       chan struct{}
+	wg        sync.WaitGroup
+	buf       []byte
+
+	mux  sync.Mutex
+	sums [][]byte
+	err  error
+}
+
+func newTreeHash(runner HashRunner, base crypto.Hash, chunkSize int) *treeHash {
+	parallel := runtime.GOMAXPROCS(0)
+	if parallel > maxTreeMemory/chunkSize {
+		parallel = maxTreeMemory / chunkSize
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+	return &treeHash{
+		runner:    runner,
+		base:      base,
+		chunkSize: chunkSize,
+		sem:       make(chan struct{}, parallel),
+	}
+}
+
+func (t *treeHash) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		free := t.chunkSize - len(t.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		t.grow(free)
+		t.buf = append(t.buf, p[:free]...)
+		p = p[free:]
+		if len(t.buf) == t.chunkSize {
+			t.hashChunk()
+		}
+	}
+	return n, t.error()
+}
+
+//grow makes room for n more bytes in buf. buf grows with the data written instead of starting at chunkSize, so small
+//inputs don't allocate whole chunks, and it never grows past chunkSize.
+func (t *treeHash) grow(n int) {
+	if cap(t.buf)-len(t.buf) >= n {
+		return
+	}
+	size := 2 * cap(t.buf)
+	if size < len(t.buf)+n {
+		size = len(t.buf) + n
+	}
+	if size > t.chunkSize {
+		size = t.chunkSize
+	}
+	buf := make([]byte, len(t.buf), size)
+	copy(buf, t.buf)
+	t.buf = buf
+}
+
+//hashChunk hashes the buffered chunk in a new goroutine
+func (t *treeHash) hashChunk() {
+	chunk := t.buf
+	t.buf = nil
+	t.mux.Lock()
+	idx := len(t.sums)
+	t.sums = append(t.sums, nil)
+	t.mux.Unlock()
+	t.sem <- struct{}{}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer func() {
+			<-t.sem
+		}()
+		sum, err := t.nodeSum(treeLeafPrefix, chunk)
+		t.mux.Lock()
+		defer t.mux.Unlock()
+		t.sums[idx] = sum
+		if t.err == nil {
+			t.err = err
+		}
+	}()
+}
+
+func (t *treeHash) nodeSum(prefix byte, data ...[]byte) ([]byte, error) {
+	var sum []byte
+	err := t.runner.WithHash(t.base, func(hsh hash.Hash) error {
+		_, e := hsh.Write([]byte{prefix})
+		if e != nil {
+			return e
+		}
+		for _, b := range data {
+			_, e = hsh.Write(b)
+			if e != nil {
+				return e
+			}
+		}
+		sum = hsh.Sum(nil)
+		return nil
+	})
+	return sum, err
+}
+
+func (t *treeHash) error() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.err
+}
+
+//root waits for all chunks to be hashed and returns the root of the tree
+func (t *treeHash) root() ([]byte, error) {
+	t.wg.Wait()
+	t.mux.Lock()
+	level := make([][]byte, len(t.sums), len(t.sums)+1)
+	copy(level, t.sums)
+	err := t.err
+	t.mux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(t.buf) > 0 || len(level) == 0 {
+		sum, err := t.nodeSum(treeLeafPrefix, t.buf)
+		if err != nil {
+			return nil, err
+		}
+		level = append(level, sum)
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			sum, err := t.nodeSum(treeNodePrefix, level[i], level[i+1])
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, sum)
+		}
+		level = next
+	}
+	return level[0], nil
+}
+
+func (t *treeHash) Sum(b []byte) []byte {
+	root, err := t.root()
+	if err != nil {
+		t.mux.Lock()
+		if t.err == nil {
+			t.err = err
+		}
+		t.mux.Unlock()
+		return b
+	}
+	return append(b, root...)
+}
+
+func (t *treeHash) Reset() {
+	t.wg.Wait()
+	t.buf = t.buf[:0]
+	t.mux.Lock()
+	t.sums = nil
+	t.err = nil
+	t.mux.Unlock()
+}
+
+func (t *treeHash) Size() int {
+	return t.base.Size()
+}
+
+func (t *treeHash) BlockSize() int {
+	return t.chunkSize
+}
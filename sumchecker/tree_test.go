@@ -0,0 +1,154 @@
+package sumchecker_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/WillAbides/checksum/knownsums/hashnames"
+	"github.com/WillAbides/checksum/sumchecker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//refTreeSum is a straightforward implementation of the tree hash to test against
+func refTreeSum(data []byte, chunkSize int) []byte {
+	var level [][]byte
+	for len(data) > 0 || len(level) == 0 {
+		n := chunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		sum := sha256.Sum256(append([]byte{0}, data[:n]...))
+		level = append(level, sum[:])
+		data = data[n:]
+	}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			node := append([]byte{1}, level[i]...)
+			sum := sha256.Sum256(append(node, level[i+1]...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+	return level[0]
+}
+
+func TestTreeHash(t *testing.T) {
+	chunkSize := 16
+	hsh := hashnames.TreeHash(crypto.SHA256, chunkSize)
+	runners := map[string]sumchecker.HashRunner{
+		"default": nil,
+		"pool":    sumchecker.NewPoolRunner(),
+	}
+	for runnerName, runner := range runners {
+		checker := sumchecker.New(runner)
+		t.Run(runnerName, func(t *testing.T) {
+			for _, size := range []int{0, 1, 15, 16, 17, 32, 48, 100, 1000} {
+				data := bytes.Repeat([]byte("x"), size)
+				want := refTreeSum(data, chunkSize)
+
+				got, err := checker.Checksum(hsh, data)
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "size %d", size)
+
+				got, err = checker.ChecksumReader(hsh, iotest.OneByteReader(bytes.NewReader(data)))
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "size %d", size)
+
+				ok, err := checker.ValidateChecksum(hsh, want, data)
+				require.NoError(t, err)
+				assert.True(t, ok)
+			}
+		})
+	}
+
+	t.Run("single chunk differs from base hash", func(t *testing.T) {
+		got, err := sumchecker.Checksum(hsh, []byte("foo"))
+		require.NoError(t, err)
+		plain, err := sumchecker.Checksum(crypto.SHA256, []byte("foo"))
+		require.NoError(t, err)
+		assert.NotEqual(t, plain, got)
+	})
+
+	t.Run("multi checksum", func(t *testing.T) {
+		data := strings.Repeat("foo", 100)
+		got, err := sumchecker.MultiChecksum([]crypto.Hash{hsh, crypto.SHA256}, strings.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, refTreeSum([]byte(data), chunkSize), got[hsh])
+		want := sha256.Sum256([]byte(data))
+		assert.Equal(t, want[:], got[crypto.SHA256])
+	})
+
+	t.Run("unregistered base hash", func(t *testing.T) {
+		_, err := sumchecker.Checksum(hashnames.TreeHash(99, chunkSize), []byte("foo"))
+		assert.EqualError(t, err, "unregistered hash")
+	})
+
+	t.Run("names", func(t *testing.T) {
+		hsh := hashnames.LookupHash("tree-sha256-1MiB")
+		assert.Equal(t, hashnames.TreeHash(crypto.SHA256, 1<<20), hsh)
+		assert.Equal(t, "tree-sha256-1MiB", hashnames.HashName(hsh))
+		assert.Equal(t, "tree-sha512-64KiB", hashnames.HashName(hashnames.TreeHash(crypto.SHA512, 64<<10)))
+		assert.Equal(t, "tree-md5-16B", hashnames.HashName(hashnames.TreeHash(crypto.MD5, 16)))
+		assert.Equal(t, crypto.Hash(0), hashnames.LookupHash("tree-sha256-3MiB"))
+		assert.Equal(t, crypto.Hash(0), hashnames.LookupHash("tree-bogus-1MiB"))
+		assert.True(t, hashnames.Available(hsh))
+		assert.False(t, hsh.Available())
+	})
+
+	t.Run("small input with large chunks", func(t *testing.T) {
+		hsh := hashnames.TreeHash(crypto.SHA256, hashnames.MaxTreeChunkSize)
+		data := []byte("foo")
+		want := refTreeSum(data, hashnames.MaxTreeChunkSize)
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		got, err := sumchecker.Checksum(hsh, data)
+		runtime.ReadMemStats(&after)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+	})
+
+	t.Run("invalid chunk size", func(t *testing.T) {
+		for _, chunkSize := range []int{0, 8, 128 << 20, 2 << 30} {
+			assert.Equal(t, crypto.Hash(0), hashnames.TreeHash(crypto.SHA256, chunkSize), "chunk size %d", chunkSize)
+		}
+		assert.Equal(t, crypto.Hash(0), hashnames.LookupHash("tree-sha256-1024GiB"))
+		assert.Equal(t, crypto.Hash(0), hashnames.LookupHash("tree-sha256-1GiB"))
+		assert.Equal(t, crypto.Hash(0), hashnames.LookupHash("tree-sha256-8B"))
+
+		//tree hashes with a shift of 64 used to have a chunk size of 0
+		hsh := hashnames.LookupHash("unknown(81925)")
+		_, _, ok := hashnames.TreeHashParams(hsh)
+		assert.False(t, ok)
+		assert.False(t, hashnames.Available(hsh))
+		assert.Equal(t, "unknown(81925)", hashnames.HashName(hsh))
+		_, err := sumchecker.Checksum(hsh, []byte("foo"))
+		assert.Equal(t, sumchecker.ErrUnregisteredHash, err)
+	})
+}
+
+func BenchmarkTreeHash(b *testing.B) {
+	data := bytes.Repeat([]byte("x"), 64<<20)
+	for _, hsh := range []crypto.Hash{crypto.SHA256, hashnames.TreeHash(crypto.SHA256, 1<<20)} {
+		b.Run(hashnames.HashName(hsh), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				_, err := sumchecker.Checksum(hsh, data)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}